@@ -1,12 +1,14 @@
 package libovsdb
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
 	"reflect"
 )
 
 var (
-	intType  = reflect.TypeOf(0)
+	intType  = reflect.TypeOf(int64(0))
 	realType = reflect.TypeOf(0.0)
 	boolType = reflect.TypeOf(true)
 	strType  = reflect.TypeOf("")
@@ -52,27 +54,220 @@ func nativeTypeFromBasic(basicType string) reflect.Type {
 	}
 }
 
+// isScalarKind returns whether kind is the kind of the native type of an atomic type
+func isScalarKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int64, reflect.Float64, reflect.Bool, reflect.String:
+		return true
+	}
+	return false
+}
+
+// isConvertibleScalar returns whether a value of type t can be used for an atomic
+// column of native type naType: t must be a named type of the same kind, e.g: a
+// string enum type, or any integer type if the column is an integer or a real
+func isConvertibleScalar(t, naType reflect.Type) bool {
+	if !isScalarKind(naType.Kind()) {
+		return false
+	}
+	if t.Kind() == naType.Kind() {
+		return true
+	}
+	if naType.Kind() != reflect.Int64 && naType.Kind() != reflect.Float64 {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// uuidsToNative returns the native representation (strings) of the UUID or the
+// []UUID given for a uuid column or a set of uuids. Other values are returned
+// as they are
+func uuidsToNative(column *ColumnSchema, rawElem interface{}) interface{} {
+	switch v := rawElem.(type) {
+	case UUID:
+		if column.Type == TypeUUID {
+			return v.GoUUID
+		}
+	case []UUID:
+		if column.Type == TypeSet && column.TypeObj.Key.Type == TypeUUID {
+			uuids := make([]string, 0, len(v))
+			for _, uuid := range v {
+				uuids = append(uuids, uuid.GoUUID)
+			}
+			return uuids
+		}
+	}
+	return rawElem
+}
+
+// convertToNative converts v to naType, the native type of a column. Atomic
+// values are converted as described in isConvertibleScalar, and so are the
+// elements of slices and the keys and values of maps (e.g: a map[string]int is
+// converted to the map[string]int64 of a map of integers)
+func convertToNative(v reflect.Value, naType reflect.Type) (reflect.Value, bool) {
+	if !v.IsValid() {
+		return v, false
+	}
+	t := v.Type()
+	if t == naType {
+		return v, true
+	}
+	switch naType.Kind() {
+	case reflect.Slice:
+		if t.Kind() != reflect.Slice || !isConvertibleScalar(t.Elem(), naType.Elem()) {
+			return v, false
+		}
+		converted := reflect.MakeSlice(naType, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			converted.Index(i).Set(v.Index(i).Convert(naType.Elem()))
+		}
+		return converted, true
+	case reflect.Map:
+		if t.Kind() != reflect.Map || !isConvertibleScalar(t.Key(), naType.Key()) || !isConvertibleScalar(t.Elem(), naType.Elem()) {
+			return v, false
+		}
+		converted := reflect.MakeMapWithSize(naType, v.Len())
+		for _, key := range v.MapKeys() {
+			converted.SetMapIndex(key.Convert(naType.Key()), v.MapIndex(key).Convert(naType.Elem()))
+		}
+		return converted, true
+	default:
+		if !isConvertibleScalar(t, naType) {
+			return v, false
+		}
+		return v.Convert(naType), true
+	}
+}
+
+// ovsNumberToNative converts a number decoded from JSON, which is either a
+// json.Number or a float64, to the native type of elemType (int64 or float64)
+// Reals with an integral value may be sent as integers, so integers are also
+// accepted for them. Other values are returned as they are
+func ovsNumberToNative(elem interface{}, elemType ExtendedType) (interface{}, error) {
+	switch elemType {
+	case TypeInteger:
+		switch n := elem.(type) {
+		case json.Number:
+			i, err := n.Int64()
+			if err != nil {
+				return nil, NewErrWrongType("OvsToNative", intType.String(), elem)
+			}
+			return i, nil
+		case float64:
+			if n != math.Trunc(n) {
+				return nil, NewErrWrongType("OvsToNative", intType.String(), elem)
+			}
+			return int64(n), nil
+		}
+	case TypeReal:
+		switch n := elem.(type) {
+		case json.Number:
+			f, err := n.Float64()
+			if err != nil {
+				return nil, NewErrWrongType("OvsToNative", realType.String(), elem)
+			}
+			return f, nil
+		case int64:
+			return float64(n), nil
+		case int:
+			return float64(n), nil
+		}
+	}
+	return elem, nil
+}
+
 // nativeValueOf returns the native value of the atomic element
 // Usually, this is just reflect.ValueOf(elem), with the only exception of the UUID
 func nativeValueOf(elem interface{}, elemType ExtendedType) (reflect.Value, error) {
 	if elemType == TypeUUID {
-		uuid, ok := elem.(UUID)
-		if !ok {
-			return reflect.ValueOf(nil), NewErrWrongType("nativeValueOf", "UUID", elem)
+		uuid, err := nativeUUID("nativeValueOf", elem)
+		if err != nil {
+			return reflect.ValueOf(nil), err
 		}
-		return reflect.ValueOf(uuid.GoUUID), nil
+		return reflect.ValueOf(uuid), nil
+	}
+	elem, err := ovsNumberToNative(elem, elemType)
+	if err != nil {
+		return reflect.ValueOf(nil), err
 	}
 	return reflect.ValueOf(elem), nil
 
 }
 
-//nativeType returns the reflect.Type that can hold the value of a column
-//OVS Type to Native Type convertions:
+// nativeUUID returns the string of a UUID received from the server, which must
+// be a real UUID: named-uuids are only meaningful within a request
+func nativeUUID(from string, elem interface{}) (string, error) {
+	uuid, ok := elem.(UUID)
+	if !ok {
+		return "", NewErrWrongType(from, "UUID", elem)
+	}
+	if uuid.validateUUID() != nil {
+		return "", fmt.Errorf("%s: %q is not a valid UUID", from, uuid.GoUUID)
+	}
+	return uuid.GoUUID, nil
+}
+
+// validateEnums checks that the native value of the column only holds the
+// values allowed by the enums of its key and value types, if any
+func validateEnums(column *ColumnSchema, native interface{}) error {
+	if column.TypeObj == nil {
+		return nil
+	}
+	v := reflect.ValueOf(native)
+	switch column.Type {
+	case TypeSet:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateEnum(column.TypeObj.Key, v.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	case TypeMap:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := validateEnum(column.TypeObj.Key, iter.Key().Interface()); err != nil {
+				return err
+			}
+			if err := validateEnum(column.TypeObj.Value, iter.Value().Interface()); err != nil {
+				return err
+			}
+		}
+	default:
+		return validateEnum(column.TypeObj.Key, native)
+	}
+	return nil
+}
+
+// validateEnum checks that elem is one of the values of the enum of the base
+// type, if it has one. The enum values are decoded from the schema, so numbers
+// are compared by value
+func validateEnum(baseType *BaseType, elem interface{}) error {
+	if baseType == nil || len(baseType.Enum) == 0 {
+		return nil
+	}
+	v := reflect.ValueOf(elem)
+	for _, e := range baseType.Enum {
+		if e == elem {
+			return nil
+		}
+		if ev := reflect.ValueOf(e); isNumber(ev) && isNumber(v) && toFloat(ev) == toFloat(v) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%v is not one of the enum values %v", elem, baseType.Enum)
+}
+
+// NativeType returns the reflect.Type that can hold the value of a column
+// OVS Type to Native Type convertions:
 // OVS sets -> go slices
 // OVS uuid -> go strings
 // OVS map  -> go map
 // OVS enum -> go native type depending on the type of the enum key
-func nativeType(column *ColumnSchema) reflect.Type {
+func NativeType(column *ColumnSchema) reflect.Type {
 	switch column.Type {
 	case TypeInteger, TypeReal, TypeBoolean, TypeUUID, TypeString:
 		return nativeTypeFromBasic(column.Type)
@@ -91,27 +286,47 @@ func nativeType(column *ColumnSchema) reflect.Type {
 }
 
 // OvsToNative transforms an ovs type to native one based on the column type information
+// Values that are not part of the enum of their column, including the elements
+// of sets and the keys and values of maps, are rejected
 func OvsToNative(column *ColumnSchema, ovsElem interface{}) (interface{}, error) {
-	naType := nativeType(column)
+	nativeElem, err := ovsToNative(column, ovsElem)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateEnums(column, nativeElem); err != nil {
+		return nil, err
+	}
+	return nativeElem, nil
+}
+
+func ovsToNative(column *ColumnSchema, ovsElem interface{}) (interface{}, error) {
+	naType := NativeType(column)
 	switch column.Type {
 	case TypeInteger, TypeReal, TypeString, TypeBoolean, TypeEnum:
+		elemType := column.Type
+		if column.Type == TypeEnum {
+			elemType = column.TypeObj.Key.Type
+		}
+		ovsElem, err := ovsNumberToNative(ovsElem, elemType)
+		if err != nil {
+			return nil, err
+		}
 		if reflect.TypeOf(ovsElem) != naType {
 			return nil, NewErrWrongType("OvsToNative", naType.String(), ovsElem)
 		}
 		// Atomic types should have the same underlying type
 		return ovsElem, nil
 	case TypeUUID:
-		uuid, ok := ovsElem.(UUID)
-		if !ok {
-			return nil, NewErrWrongType("OvsToNative", "UUID", ovsElem)
-		}
-		return uuid.GoUUID, nil
+		return nativeUUID("OvsToNative", ovsElem)
 	case TypeSet:
 		// The inner slice is []interface{}
 		// We need to convert it to the real type os slice
 		var nativeSet reflect.Value
 
 		// RFC says that for a set of exactly one, an atomic type an be sent
+		if ovsSet, ok := ovsElem.(*OvsSet); ok && ovsSet != nil {
+			ovsElem = *ovsSet
+		}
 		switch ovsElem.(type) {
 		case OvsSet:
 			ovsSet := ovsElem.(OvsSet)
@@ -136,7 +351,7 @@ func OvsToNative(column *ColumnSchema, ovsElem interface{}) (interface{}, error)
 				return nil, err
 			}
 
-			if !vv.Type().ConvertibleTo(keyType) {
+			if vv.Type() != keyType {
 				return nil, NewErrWrongType("OvsToNative", keyType.String(), ovsElem)
 			}
 			nativeSet = reflect.Append(nativeSet, vv)
@@ -144,6 +359,10 @@ func OvsToNative(column *ColumnSchema, ovsElem interface{}) (interface{}, error)
 		return nativeSet.Interface(), nil
 
 	case TypeMap:
+		// NativeToOvs returns a *OvsMap
+		if ovsMap, ok := ovsElem.(*OvsMap); ok && ovsMap != nil {
+			ovsElem = *ovsMap
+		}
 		ovsMap, ok := ovsElem.(OvsMap)
 		if !ok {
 			return nil, NewErrWrongType("OvsToNative", "OvsMap", ovsElem)
@@ -171,12 +390,40 @@ func OvsToNative(column *ColumnSchema, ovsElem interface{}) (interface{}, error)
 	}
 }
 
+// isNativeValueOf returns whether NativeToOvs accepts the type of the value
+// for the column
+func isNativeValueOf(column *ColumnSchema, value interface{}) bool {
+	naType := NativeType(column)
+	value = uuidsToNative(column, value)
+	if reflect.TypeOf(value) == naType {
+		return true
+	}
+	_, ok := convertToNative(reflect.ValueOf(value), naType)
+	return ok
+}
+
 // NativeToOvs transforms an native type to a ovs type based on the column type information
+// Columns also accept named types with the right underlying type (e.g: type
+// MyEnum string) and any Go integer type for integers and reals, which are
+// converted to int64 or float64. The same applies to the elements of sets and
+// to the keys and values of maps (e.g: []int or map[string]int).
+// UUIDs are natively represented as strings, which is what OvsToNative returns,
+// but uuid columns and sets of uuids also accept UUID and []UUID values.
+// Like in OvsToNative, values that are not part of the enum of their column are
+// rejected
 func NativeToOvs(column *ColumnSchema, rawElem interface{}) (interface{}, error) {
-	naType := nativeType(column)
+	naType := NativeType(column)
 
-	if t := reflect.TypeOf(rawElem); t != naType {
-		return nil, NewErrWrongType("NativeToOvs", naType.String(), rawElem)
+	rawElem = uuidsToNative(column, rawElem)
+	if reflect.TypeOf(rawElem) != naType {
+		converted, ok := convertToNative(reflect.ValueOf(rawElem), naType)
+		if !ok {
+			return nil, NewErrWrongType("NativeToOvs", naType.String(), rawElem)
+		}
+		rawElem = converted.Interface()
+	}
+	if err := validateEnums(column, rawElem); err != nil {
+		return nil, err
 	}
 
 	switch column.Type {
@@ -185,21 +432,17 @@ func NativeToOvs(column *ColumnSchema, rawElem interface{}) (interface{}, error)
 	case TypeUUID:
 		return UUID{GoUUID: rawElem.(string)}, nil
 	case TypeSet:
-		var ovsSet *OvsSet
 		if column.TypeObj.Key.Type == TypeUUID {
 			var ovsSlice []interface{}
 			for _, v := range rawElem.([]string) {
 				uuid := UUID{GoUUID: v}
 				ovsSlice = append(ovsSlice, uuid)
 			}
-			ovsSet = &OvsSet{GoSet: ovsSlice}
-
-		} else {
-			var err error
-			ovsSet, err = NewOvsSet(rawElem)
-			if err != nil {
-				return nil, err
-			}
+			rawElem = ovsSlice
+		}
+		ovsSet, err := NewOvsSet(rawElem)
+		if err != nil {
+			return nil, err
 		}
 		return ovsSet, nil
 	case TypeMap:
@@ -1,6 +1,7 @@
 package libovsdb
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"reflect"
@@ -24,7 +25,7 @@ var (
 		aUUID3,
 	}
 
-	aIntSet = []int{
+	aIntSet = []int64{
 		0,
 		1,
 		2,
@@ -38,6 +39,8 @@ var (
 		42.0,
 	}
 
+	aBoolSet = []bool{true, false}
+
 	aMap = map[string]string{
 		"key1": "value1",
 		"key2": "value2",
@@ -56,6 +59,7 @@ func getColnames() []string {
 		"aIntSet",
 		"aFloat",
 		"aFloatSet",
+		"aBoolSet",
 		"aMap",
 		"aUUID",
 		"aEmptySet",
@@ -83,13 +87,13 @@ func getErrTransMaps() []map[string]interface{} {
 		"name":   "Wrong Atomic Numeric Type: Int",
 		"schema": []byte(`{"type":"integer"}`),
 		"native": 42.0,
-		"ovs":    42.0,
+		"ovs":    42.5,
 	})
 	transMap = append(transMap, map[string]interface{}{
 		"name":   "Wrong Atomic Numeric Type: Float",
 		"schema": []byte(`{"type":"real"}`),
-		"native": 42,
-		"ovs":    42,
+		"native": "42",
+		"ovs":    "42",
 	})
 	as, _ := NewOvsSet([]string{"foo"})
 	transMap = append(transMap, map[string]interface{}{
@@ -243,6 +247,25 @@ func getTransMaps() []map[string]interface{} {
 		"ovs2native": []string{aUUID0},
 	})
 
+	// Optional string (set with min 0, max 1), with and without a value
+	s0, _ := NewOvsSet([]string{})
+	transMap = append(transMap, map[string]interface{}{
+		"name":       "Optional String without value",
+		"schema":     []byte(`{"type":{"key":"string","min":0,"max":1}}`),
+		"native":     []string{},
+		"native2ovs": s0,
+		"ovs":        *s0,
+		"ovs2native": []string{},
+	})
+	transMap = append(transMap, map[string]interface{}{
+		"name":       "Optional String with value",
+		"schema":     []byte(`{"type":{"key":"string","min":0,"max":1}}`),
+		"native":     []string{aString},
+		"native2ovs": s1,
+		"ovs":        aString,
+		"ovs2native": []string{aString},
+	})
+
 	// A integer set
 	is, _ := NewOvsSet(aIntSet)
 	transMap = append(transMap, map[string]interface{}{
@@ -281,6 +304,25 @@ func getTransMaps() []map[string]interface{} {
 		"ovs2native": aFloatSet,
 	})
 
+	// A boolean set
+	bs, _ := NewOvsSet(aBoolSet)
+	transMap = append(transMap, map[string]interface{}{
+		"name": "Boolean Set",
+		"schema": []byte(`{
+	"type":{
+            "key": {
+              "type": "boolean"
+            },
+            "min": 0,
+            "max": 2
+          }
+        }`),
+		"native":     aBoolSet,
+		"native2ovs": bs,
+		"ovs":        *bs,
+		"ovs2native": aBoolSet,
+	})
+
 	// A empty string set
 	es, _ := NewOvsSet(aEmptySet)
 	transMap = append(transMap, map[string]interface{}{
@@ -439,6 +481,201 @@ func TestOvsToNativeErr(t *testing.T) {
 	}
 }
 
+func TestMapAndSetRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   []byte
+		native   interface{}
+		expected interface{}
+	}{
+		{
+			name:     "map of strings",
+			schema:   []byte(`{"type":{"key":"string","value":"string","min":0,"max":"unlimited"}}`),
+			native:   map[string]string{"foo": "bar"},
+			expected: map[string]string{"foo": "bar"},
+		},
+		{
+			name:     "map of integers",
+			schema:   []byte(`{"type":{"key":"string","value":"integer","min":0,"max":"unlimited"}}`),
+			native:   map[string]int{"foo": 1, "bar": 1 << 40},
+			expected: map[string]int64{"foo": 1, "bar": 1 << 40},
+		},
+		{
+			name:     "map of reals",
+			schema:   []byte(`{"type":{"key":"integer","value":"real","min":0,"max":"unlimited"}}`),
+			native:   map[int]int{1: 2},
+			expected: map[int64]float64{1: 2.0},
+		},
+		{
+			name:     "set of integers",
+			schema:   []byte(`{"type":{"key":"integer","min":0,"max":"unlimited"}}`),
+			native:   []int{1, 2},
+			expected: []int64{1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var column ColumnSchema
+			if err := json.Unmarshal(tt.schema, &column); err != nil {
+				t.Fatal(err)
+			}
+			ovs, err := NativeToOvs(&column, tt.native)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Converting back what we've just produced must work
+			native, err := OvsToNative(&column, ovs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tt.expected, native) {
+				t.Errorf("Expected %v (%T), got %v (%T)", tt.expected, tt.expected, native, native)
+			}
+
+			b, err := json.Marshal(ovs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded interface{}
+			if column.Type == TypeMap {
+				var m OvsMap
+				err = json.Unmarshal(b, &m)
+				decoded = m
+			} else {
+				var s OvsSet
+				err = json.Unmarshal(b, &s)
+				decoded = s
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			native, err = OvsToNative(&column, decoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tt.expected, native) {
+				t.Errorf("Expected %v (%T), got %v (%T)", tt.expected, tt.expected, native, native)
+			}
+		})
+	}
+
+	var column ColumnSchema
+	if err := json.Unmarshal([]byte(`{"type":{"key":"string","value":"integer","min":0,"max":"unlimited"}}`), &column); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NativeToOvs(&column, map[string]string{"foo": "1"}); err == nil {
+		t.Error("Expected a map of strings not to be accepted for a map of integers")
+	}
+}
+
+func TestNativeToOvsUUIDs(t *testing.T) {
+	var column ColumnSchema
+	if err := json.Unmarshal([]byte(`{"type":{"key":"uuid","min":0,"max":"unlimited"}}`), &column); err != nil {
+		t.Fatal(err)
+	}
+	fromStrings, err := NativeToOvs(&column, aUUIDSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuids := make([]UUID, 0, len(aUUIDSet))
+	for _, u := range aUUIDSet {
+		uuids = append(uuids, UUID{GoUUID: u})
+	}
+	fromUUIDs, err := NativeToOvs(&column, uuids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromStrings, fromUUIDs) {
+		t.Errorf("Expected %v, got %v", fromStrings, fromUUIDs)
+	}
+
+	if err := json.Unmarshal([]byte(`{"type":"uuid"}`), &column); err != nil {
+		t.Fatal(err)
+	}
+	res, err := NativeToOvs(&column, UUID{GoUUID: aUUID0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != (UUID{GoUUID: aUUID0}) {
+		t.Errorf("Expected %v, got %v", UUID{GoUUID: aUUID0}, res)
+	}
+
+	// UUIDs are not accepted for columns of other types
+	if err := json.Unmarshal([]byte(`{"type":{"key":"string","min":0,"max":"unlimited"}}`), &column); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NativeToOvs(&column, uuids); err == nil {
+		t.Error("Expected []UUID not to be accepted for a set of strings")
+	}
+}
+
+func TestOvsToNativeInvalidUUID(t *testing.T) {
+	schemas := map[string][]byte{
+		"uuid": []byte(`{"type":"uuid"}`),
+		"set":  []byte(`{"type":{"key":"uuid","min":0,"max":"unlimited"}}`),
+		"map":  []byte(`{"type":{"key":"string","value":"uuid","min":0,"max":"unlimited"}}`),
+	}
+	for _, invalid := range []string{"gopher", "2f77b348-9768-4866-b761", "2F77B348-9768-4866-B761-89D5177ECDA0"} {
+		ovs := map[string]interface{}{
+			"uuid": UUID{GoUUID: invalid},
+			"set":  OvsSet{GoSet: []interface{}{UUID{GoUUID: aUUID0}, UUID{GoUUID: invalid}}},
+			"map":  OvsMap{GoMap: map[interface{}]interface{}{"key": UUID{GoUUID: invalid}}},
+		}
+		for name, schema := range schemas {
+			var column ColumnSchema
+			if err := json.Unmarshal(schema, &column); err != nil {
+				t.Fatal(err)
+			}
+			if res, err := OvsToNative(&column, ovs[name]); err == nil {
+				t.Errorf("Expected %s with UUID %q to be rejected, got %v", name, invalid, res)
+			}
+		}
+	}
+}
+
+func TestOvsToNativeIntegralReal(t *testing.T) {
+	schemas := map[string][]byte{
+		"real": []byte(`{"type":"real"}`),
+		"set":  []byte(`{"type":{"key":"real","min":0,"max":"unlimited"}}`),
+		"map":  []byte(`{"type":{"key":"string","value":"real","min":0,"max":"unlimited"}}`),
+	}
+	ovs := map[string][]byte{
+		"real": []byte(`{"column":5}`),
+		"set":  []byte(`{"column":["set",[5,1.5]]}`),
+		"map":  []byte(`{"column":["map",[["key",5]]]}`),
+	}
+	expected := map[string]interface{}{
+		"real": 5.0,
+		"set":  []float64{5.0, 1.5},
+		"map":  map[string]float64{"key": 5.0},
+	}
+	for name, schema := range schemas {
+		var column ColumnSchema
+		if err := json.Unmarshal(schema, &column); err != nil {
+			t.Fatal(err)
+		}
+		var row Row
+		if err := json.Unmarshal(ovs[name], &row); err != nil {
+			t.Fatal(err)
+		}
+		res, err := OvsToNative(&column, row.Fields["column"])
+		if err != nil {
+			t.Errorf("Failed to convert %s: %s", name, err)
+		} else if !reflect.DeepEqual(res, expected[name]) {
+			t.Errorf("Expected %s to be %#v, got %#v", name, expected[name], res)
+		}
+	}
+
+	// Integers already decoded, e.g: in a row built by hand
+	var column ColumnSchema
+	if err := json.Unmarshal(schemas["real"], &column); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := OvsToNative(&column, int64(5)); err != nil || res != 5.0 {
+		t.Errorf("Expected int64 5 to be 5.0, got %v (%v)", res, err)
+	}
+}
+
 func TestNativeToOvsErr(t *testing.T) {
 	transMaps := getErrTransMaps()
 	for _, trans := range transMaps {
@@ -456,3 +693,245 @@ func TestNativeToOvsErr(t *testing.T) {
 		})
 	}
 }
+
+func TestNativeType(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   []byte
+		expected reflect.Type
+	}{
+		{
+			name:     "integer",
+			schema:   []byte(`{"type":"integer"}`),
+			expected: reflect.TypeOf(int64(0)),
+		},
+		{
+			name:     "uuid",
+			schema:   []byte(`{"type":"uuid"}`),
+			expected: reflect.TypeOf(""),
+		},
+		{
+			name: "integer set",
+			schema: []byte(`{
+	"type":{
+            "key": {
+              "type": "integer"
+            },
+            "min": 0,
+            "max": "unlimited"
+          }
+        }`),
+			expected: reflect.TypeOf([]int64{}),
+		},
+		{
+			name:     "boolean set",
+			schema:   []byte(`{"type":{"key":"boolean","min":0,"max":"unlimited"}}`),
+			expected: reflect.TypeOf([]bool{}),
+		},
+		{
+			name: "string map",
+			schema: []byte(`{
+          "type": {
+            "key": "string",
+            "max": "unlimited",
+            "min": 0,
+            "value": "string"
+          }
+	}`),
+			expected: reflect.TypeOf(map[string]string{}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("NativeType: %s", tt.name), func(t *testing.T) {
+			var column ColumnSchema
+			if err := json.Unmarshal(tt.schema, &column); err != nil {
+				t.Fatal(err)
+			}
+			if got := NativeType(&column); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestOptionalSetRoundTrip(t *testing.T) {
+	schemas := map[string][]byte{
+		"string":  []byte(`{"type":{"key":"string","min":0,"max":1}}`),
+		"uuid":    []byte(`{"type":{"key":{"type":"uuid"},"min":0,"max":1}}`),
+		"integer": []byte(`{"type":{"key":"integer","min":0,"max":1}}`),
+		"real":    []byte(`{"type":{"key":"real","min":0,"max":1}}`),
+		"boolean": []byte(`{"type":{"key":"boolean","min":0,"max":1}}`),
+	}
+	values := map[string][]interface{}{
+		"string":  {[]string{}, []string{aString}},
+		"uuid":    {[]string{}, []string{aUUID0}},
+		"integer": {[]int64{}, []int64{42}},
+		"real":    {[]float64{}, []float64{42.5}},
+		"boolean": {[]bool{}, []bool{false}},
+	}
+	for name, schema := range schemas {
+		var column ColumnSchema
+		if err := json.Unmarshal(schema, &column); err != nil {
+			t.Fatal(err)
+		}
+		for _, native := range values[name] {
+			ovs, err := NativeToOvs(&column, native)
+			if err != nil {
+				t.Fatalf("%s %v: %s", name, native, err)
+			}
+			// Converting back what we've just produced must work
+			res, err := OvsToNative(&column, ovs)
+			if err != nil {
+				t.Fatalf("%s %v: %s", name, native, err)
+			}
+			if !reflect.DeepEqual(res, native) {
+				t.Errorf("%s: expected %v, got %v", name, native, res)
+			}
+
+			// And so must converting it back after going through the wire,
+			// where a single element is sent without the set notation
+			b, err := json.Marshal(map[string]interface{}{"column": ovs})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reflect.ValueOf(native).Len() == 1 && bytes.Contains(b, []byte(`"set"`)) {
+				t.Errorf("%s: expected a bare value, got %s", name, b)
+			}
+			var row ResultRow
+			if err := json.Unmarshal(b, &row); err != nil {
+				t.Fatal(err)
+			}
+			res, err = OvsToNative(&column, row["column"])
+			if err != nil {
+				t.Fatalf("%s %v: %s", name, native, err)
+			}
+			if !reflect.DeepEqual(res, native) {
+				t.Errorf("%s: expected %v, got %v", name, native, res)
+			}
+
+			// The server may also use the set notation for a single element
+			b = bytes.Replace(b, []byte(`{"column":`), []byte(`{"column":["set",[`), 1)
+			b = bytes.Replace(b, []byte(`}`), []byte(`]]}`), 1)
+			if reflect.ValueOf(native).Len() == 0 {
+				continue
+			}
+			if err := json.Unmarshal(b, &row); err != nil {
+				t.Fatalf("%s: %s: %s", name, b, err)
+			}
+			res, err = OvsToNative(&column, row["column"])
+			if err != nil {
+				t.Fatalf("%s %v: %s", name, native, err)
+			}
+			if !reflect.DeepEqual(res, native) {
+				t.Errorf("%s: expected %v, got %v", name, native, res)
+			}
+		}
+	}
+}
+
+type testEnum string
+
+type testFlag bool
+
+func TestNativeToOvsNamedTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   []byte
+		native   interface{}
+		expected interface{}
+	}{
+		{
+			name:     "enum",
+			schema:   []byte(`{"type":{"key":{"type":"string","enum":["set",["enum1","enum2"]]}}}`),
+			native:   testEnum("enum1"),
+			expected: "enum1",
+		},
+		{
+			name:     "boolean",
+			schema:   []byte(`{"type":"boolean"}`),
+			native:   true,
+			expected: true,
+		},
+		{
+			name:     "integer as real",
+			schema:   []byte(`{"type":"real"}`),
+			native:   42,
+			expected: 42.0,
+		},
+		{
+			name:     "int64 as real",
+			schema:   []byte(`{"type":"real"}`),
+			native:   int64(42),
+			expected: 42.0,
+		},
+		{
+			name:     "named boolean",
+			schema:   []byte(`{"type":"boolean"}`),
+			native:   testFlag(true),
+			expected: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("NativeToOvs: %s", tt.name), func(t *testing.T) {
+			var column ColumnSchema
+			if err := json.Unmarshal(tt.schema, &column); err != nil {
+				t.Fatal(err)
+			}
+			res, err := NativeToOvs(&column, tt.native)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res != tt.expected {
+				t.Errorf("Expected %v (%T), got %v (%T)", tt.expected, tt.expected, res, res)
+			}
+		})
+	}
+
+	// The underlying type must still match
+	var column ColumnSchema
+	if err := json.Unmarshal([]byte(`{"type":"integer"}`), &column); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NativeToOvs(&column, testEnum("1")); err == nil {
+		t.Error("Expected a named string not to be accepted for an integer column")
+	}
+	if _, err := NativeToOvs(&column, 42.0); err == nil {
+		t.Error("Expected a float not to be accepted for an integer column")
+	}
+}
+
+func TestEnumSetValidation(t *testing.T) {
+	var column ColumnSchema
+	if err := json.Unmarshal([]byte(`{"type":{"key":{"type":"string","enum":["set",["tcp","udp","sctp"]]},"min":0,"max":"unlimited"}}`), &column); err != nil {
+		t.Fatal(err)
+	}
+
+	ovsElem, err := NativeToOvs(&column, []string{"tcp", "udp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	native, err := OvsToNative(&column, ovsElem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(native, []string{"tcp", "udp"}) {
+		t.Errorf("Expected [tcp udp], got %v", native)
+	}
+	if _, err := NativeToOvs(&column, []testEnum{"sctp"}); err != nil {
+		t.Errorf("Expected a named string enum member to be accepted: %v", err)
+	}
+
+	if _, err := NativeToOvs(&column, []string{"tcp", "icmp"}); err == nil {
+		t.Error("Expected a member outside of the enum not to be accepted by NativeToOvs")
+	}
+	var set OvsSet
+	if err := json.Unmarshal([]byte(`["set",["udp","icmp"]]`), &set); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OvsToNative(&column, set); err == nil {
+		t.Error("Expected a member outside of the enum not to be accepted by OvsToNative")
+	}
+	if _, err := OvsToNative(&column, "icmp"); err == nil {
+		t.Error("Expected a single member outside of the enum not to be accepted by OvsToNative")
+	}
+}
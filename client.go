@@ -1,34 +1,84 @@
 package libovsdb
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net"
-	"net/url"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/cenkalti/rpc2"
 	"github.com/cenkalti/rpc2/jsonrpc"
 )
 
 // OvsdbClient is an OVSDB client
+// The schema and the NativeAPI of each database are updated by GetSchema, which
+// can run at any time, so they are read with SchemaFor and APIFor, which are
+// safe to call from multiple goroutines
 type OvsdbClient struct {
 	rpcClient     *rpc2.Client
-	Schema        map[string]DatabaseSchema
-	Apis          map[string]NativeAPI
+	conn          io.Closer
+	endpoint      string
+	schemas       map[string]DatabaseSchema
+	apis          map[string]NativeAPI
+	schemaMutex   *sync.RWMutex
 	handlers      []NotificationHandler
 	handlersMutex *sync.Mutex
+	connected     bool
+	closed        bool
+	probeStop     chan struct{}
+	onConnect     []func(*OvsdbClient)
+	onDisconnect  []func(*OvsdbClient)
+	stateMutex    *sync.RWMutex
+	monitors      map[string]*monitor
+	// cancelled holds the keys of the monitors cancelled by the client, whose
+	// late updates are dropped, until a monitor with the same key is issued
+	cancelled     map[string]struct{}
+	// cancelling holds the keys of the monitors whose monitor_cancel request is
+	// being sent, which cannot be issued again until it is
+	cancelling    map[string]struct{}
+	monitorsMutex *sync.Mutex
+	// requestTimeout bounds the requests waiting for a reply, zero means no timeout
+	requestTimeout time.Duration
+	// metrics, if set, is notified of the transactions, updates and requests
+	metrics MetricsObserver
+	// pendingRequests counts the requests waiting for a reply
+	pendingRequests int32
+	// timeouts counts the requests that timed out since the last reply
+	timeouts int32
+	// transactionComment, if set, returns the comment added to the transactions
+	transactionComment func() string
+}
+
+// monitor holds the parameters of an active monitor
+type monitor struct {
+	database string
+	requests map[string]MonitorRequest
+	// handler, if set, receives the updates instead of the registered handlers
+	handler NotificationHandler
 }
 
 func newOvsdbClient(c *rpc2.Client) *OvsdbClient {
 	ovs := &OvsdbClient{
 		rpcClient:     c,
-		Schema:        make(map[string]DatabaseSchema),
+		schemas:       make(map[string]DatabaseSchema),
+		apis:          make(map[string]NativeAPI),
+		schemaMutex:   &sync.RWMutex{},
 		handlersMutex: &sync.Mutex{},
+		stateMutex:    &sync.RWMutex{},
+		monitors:      make(map[string]*monitor),
+		cancelled:     make(map[string]struct{}),
+		cancelling:    make(map[string]struct{}),
+		monitorsMutex: &sync.Mutex{},
 	}
 	return ovs
 }
@@ -43,6 +93,7 @@ var (
 // Constants defined for libovsdb
 const (
 	defaultTCPAddress  = "127.0.0.1:6640"
+	defaultTCPPort     = "6640"
 	defaultUnixAddress = "/var/run/openvswitch/ovnnb_db.sock"
 	SSL                = "ssl"
 	TCP                = "tcp"
@@ -51,83 +102,185 @@ const (
 
 // Connect to ovn, using endpoint in format ovsdb Connection Methods
 // If address is empty, use default address for specified protocol
+// endpoints can be a comma-separated list of endpoints (e.g: the members of a
+// clustered database). They are tried in order until a connection succeeds
 func Connect(endpoints string, tlsConfig *tls.Config) (*OvsdbClient, error) {
-	var c net.Conn
-	var err error
-	var u *url.URL
+	return ConnectWithDialer(endpoints, tlsConfig, nil)
+}
+
+// Dialer opens the connections to the endpoints, e.g: a net.Dialer with a
+// custom resolver or a proxy dialer
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// ConnectWithDialer connects like Connect but opens the connections with the
+// provided dialer. For ssl endpoints, the TLS handshake is done over the
+// connection returned by the dialer. A nil dialer behaves like Connect
+func ConnectWithDialer(endpoints string, tlsConfig *tls.Config, dialer Dialer) (*OvsdbClient, error) {
+	var errs []string
 
 	for _, endpoint := range strings.Split(endpoints, ",") {
-		if u, err = url.Parse(endpoint); err != nil {
+		endpoint = strings.TrimSpace(endpoint)
+		network, address, err := parseEndpoint(endpoint)
+		if err != nil {
 			return nil, err
 		}
-		// u.Opaque contains the original endPoint with the leading protocol stripped
-		// off. For example: endPoint is "tcp:127.0.0.1:6640" and u.Opaque is "127.0.0.1:6640"
-		host := u.Opaque
-		if len(host) == 0 {
-			host = defaultTCPAddress
-		}
-		switch u.Scheme {
-		case UNIX:
-			path := u.Path
-			if len(path) == 0 {
-				path = defaultUnixAddress
+		var c net.Conn
+		if dialer != nil {
+			c, err = dial(dialer, network, address, tlsConfig)
+		} else {
+			switch network {
+			case UNIX, TCP:
+				c, err = net.Dial(network, address)
+			case SSL:
+				c, err = tls.Dial("tcp", address, tlsConfig)
 			}
-			c, err = net.Dial(u.Scheme, path)
-		case TCP:
-			c, err = net.Dial(u.Scheme, host)
-		case SSL:
-			c, err = tls.Dial("tcp", host, tlsConfig)
-		default:
-			err = fmt.Errorf("unknown network protocol %s", u.Scheme)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", endpoint, err))
+			continue
 		}
 
-		if err == nil {
-			return newRPC2Client(c)
+		ovs, err := ConnectWithConn(c, "", nil)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", endpoint, err))
+			continue
 		}
+		ovs.endpoint = endpoint
+		return ovs, nil
 	}
 
-	return nil, fmt.Errorf("failed to connect to endpoints %q: %v", endpoints, err)
+	return nil, fmt.Errorf("failed to connect to endpoints %q: %s", endpoints, strings.Join(errs, "; "))
 }
 
-func newRPC2Client(conn net.Conn) (*OvsdbClient, error) {
-	c := rpc2.NewClientWithCodec(jsonrpc.NewJSONCodec(conn))
-	c.SetBlocking(true)
-	c.Handle("echo", echo)
-	c.Handle("update", update)
-	go c.Run()
-	go handleDisconnectNotification(c)
-
-	ovs := newOvsdbClient(c)
-
-	// Process Async Notifications
-	dbs, err := ovs.ListDbs()
+// dial opens a connection to address with dialer, doing the TLS handshake for
+// ssl endpoints
+func dial(dialer Dialer, network, address string, tlsConfig *tls.Config) (net.Conn, error) {
+	if network != SSL {
+		return dialer.DialContext(context.Background(), network, address)
+	}
+	c, err := dialer.DialContext(context.Background(), "tcp", address)
 	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{}
+	if tlsConfig != nil {
+		config = tlsConfig.Clone()
+	}
+	if config.ServerName == "" {
+		// As tls.Dial does, verify the certificate against the endpoint host
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			config.ServerName = host
+		}
+	}
+	tlsConn := tls.Client(c, config)
+	if err := tlsConn.Handshake(); err != nil {
 		c.Close()
 		return nil, err
 	}
+	return tlsConn, nil
+}
 
-	ovs.Apis = make(map[string]NativeAPI)
-	for _, db := range dbs {
-		schema, err := ovs.GetSchema(db)
-		if err == nil {
-			ovs.Schema[db] = *schema
-			ovs.Apis[db] = NewNativeAPI(schema)
-		} else {
-			c.Close()
-			return nil, err
+// Endpoint returns the endpoint the client is connected to. It is empty if the
+// client was not created by Connect
+func (ovs *OvsdbClient) Endpoint() string {
+	return ovs.endpoint
+}
+
+// parseEndpoint returns the network and address of an endpoint in the format of
+// the ovsdb Connection Methods, e.g: "tcp:127.0.0.1:6640", "ssl:[::1]:6640" or
+// "unix:/var/run/openvswitch/db.sock".
+// An empty address is replaced by the default one of the protocol and a missing
+// port by the default OVSDB port. IPv6 addresses must be enclosed in brackets
+func parseEndpoint(endpoint string) (string, string, error) {
+	parts := strings.SplitN(endpoint, ":", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("malformed endpoint %q", endpoint)
+	}
+	network, address := parts[0], parts[1]
+	switch network {
+	case UNIX:
+		if len(address) == 0 {
+			address = defaultUnixAddress
 		}
+		return network, address, nil
+	case TCP, SSL:
+		if len(address) == 0 {
+			return network, defaultTCPAddress, nil
+		}
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			// The address might just lack the port
+			host, port = address, defaultTCPPort
+			if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+				host = host[1 : len(host)-1]
+			} else if strings.Contains(host, ":") {
+				return "", "", fmt.Errorf("malformed endpoint %q: IPv6 addresses must be enclosed in brackets", endpoint)
+			}
+		}
+		if len(host) == 0 {
+			return "", "", fmt.Errorf("malformed endpoint %q: missing host", endpoint)
+		}
+		if len(port) == 0 {
+			port = defaultTCPPort
+		}
+		return network, net.JoinHostPort(host, port), nil
+	default:
+		return "", "", fmt.Errorf("unknown network protocol %s", network)
+	}
+}
+
+// ConnectWithConn creates an OVSDB client that runs the JSON-RPC protocol over
+// an already established connection instead of dialing one.
+// If database is not empty, only the schema of that database is retrieved.
+// Otherwise, the schemas of every database on the server are retrieved.
+// If notifier is not nil, it is registered before any notification can be received
+func ConnectWithConn(conn net.Conn, database string, notifier NotificationHandler) (*OvsdbClient, error) {
+	c := rpc2.NewClientWithCodec(newJSONCodec(conn))
+	c.SetBlocking(true)
+	c.Handle("echo", echo)
+	c.Handle("update", update)
+	c.Handle("locked", locked)
+	c.Handle("stolen", stolen)
+	ovs := newOvsdbClient(c)
+	ovs.conn = conn
+	if notifier != nil {
+		ovs.Register(notifier)
 	}
 
 	connectionsMutex.Lock()
-	defer connectionsMutex.Unlock()
 	if connections == nil {
 		connections = make(map[*rpc2.Client]*OvsdbClient)
 	}
 	connections[c] = ovs
+	connectionsMutex.Unlock()
+
+	ovs.setConnected(true)
+	go c.Run()
+	go ovs.handleDisconnectNotification()
+
+	dbs := []string{database}
+	if database == "" {
+		var err error
+		dbs, err = ovs.ListDbs()
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	for _, db := range dbs {
+		if _, err := ovs.GetSchema(db); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
 	return ovs, nil
 }
 
 // Register registers the supplied NotificationHandler to recieve OVSDB Notifications
+// Several handlers can be registered: every notification is delivered to all of them
 func (ovs *OvsdbClient) Register(handler NotificationHandler) {
 	ovs.handlersMutex.Lock()
 	defer ovs.handlersMutex.Unlock()
@@ -156,6 +309,47 @@ func (ovs *OvsdbClient) Unregister(handler NotificationHandler) error {
 	return nil
 }
 
+// UpdatesChannel returns a channel delivering the updates of the monitors that
+// do not have their own handler, and a function to stop the delivery and close
+// the channel. Updates are never queued beyond the buffer: when it is full, the
+// update is dropped and logged so that a slow reader does not block the other
+// handlers, and readers that cannot miss updates should use a NotificationHandler
+func (ovs *OvsdbClient) UpdatesChannel(buffer int) (<-chan TableUpdates, func()) {
+	handler := &updatesHandler{updates: make(chan TableUpdates, buffer)}
+	ovs.Register(handler)
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			// Once unregistered the handler is no longer called, so the
+			// channel can be closed safely
+			ovs.Unregister(handler)
+			close(handler.updates)
+		})
+	}
+	return handler.updates, cancel
+}
+
+// updatesHandler is the NotificationHandler behind UpdatesChannel
+type updatesHandler struct {
+	updates chan TableUpdates
+}
+
+func (h *updatesHandler) Update(context interface{}, tableUpdates TableUpdates) {
+	select {
+	case h.updates <- tableUpdates:
+	default:
+		log.Printf("libovsdb: updates channel full, dropping update of monitor %v", context)
+	}
+}
+func (h *updatesHandler) Locked([]interface{}) {
+}
+func (h *updatesHandler) Stolen([]interface{}) {
+}
+func (h *updatesHandler) Echo([]interface{}) {
+}
+func (h *updatesHandler) Disconnected(*OvsdbClient) {
+}
+
 // NotificationHandler is the interface that must be implemented to receive notifcations
 type NotificationHandler interface {
 	// RFC 7047 section 4.1.6 Update Notification
@@ -168,47 +362,127 @@ type NotificationHandler interface {
 	Stolen([]interface{})
 
 	// RFC 7047 section 4.1.11 Echo Notification
+	// It is called with the params of the echo requests of the server, which
+	// the client always answers by itself to keep the connection alive
 	Echo([]interface{})
 
 	Disconnected(*OvsdbClient)
 }
 
 // RFC 7047 : Section 4.1.6 : Echo
+// The reply is sent whatever the handlers do: ovsdb-server closes the
+// connections that do not answer its echo requests
 func echo(client *rpc2.Client, args []interface{}, reply *[]interface{}) error {
 	*reply = args
+	forEachHandler(client, "echo", func(handler NotificationHandler) { handler.Echo(args) })
+	return nil
+}
+
+// RFC 7047 : Section 4.1.9 : Locked
+func locked(client *rpc2.Client, args []interface{}, _ *interface{}) error {
+	forEachHandler(client, "locked", func(handler NotificationHandler) { handler.Locked(args) })
+	return nil
+}
+
+// RFC 7047 : Section 4.1.10 : Stolen
+func stolen(client *rpc2.Client, args []interface{}, _ *interface{}) error {
+	forEachHandler(client, "stolen", func(handler NotificationHandler) { handler.Stolen(args) })
+	return nil
+}
+
+// forEachHandler calls f with every handler registered on the client of the connection
+func forEachHandler(client *rpc2.Client, event string, f func(NotificationHandler)) {
 	connectionsMutex.RLock()
 	defer connectionsMutex.RUnlock()
-	if _, ok := connections[client]; ok {
-		connections[client].handlersMutex.Lock()
-		defer connections[client].handlersMutex.Unlock()
-		for _, handler := range connections[client].handlers {
-			handler.Echo(nil)
+	if ovs, ok := connections[client]; ok {
+		ovs.handlersMutex.Lock()
+		defer ovs.handlersMutex.Unlock()
+		for _, handler := range ovs.handlers {
+			notify(event, nil, func() { f(handler) })
 		}
 	}
+}
+
+// notify runs f, a call to one of the NotificationHandler methods for the given
+// event, recovering from a panic in the handler so that it does not take down
+// the client nor prevent the other handlers from being notified
+func notify(event string, tableUpdates *TableUpdates, f func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			if tableUpdates == nil {
+				log.Printf("libovsdb: recovered from panic in %s handler: %v", event, r)
+				return
+			}
+			tables := make([]string, 0, len(tableUpdates.Updates))
+			for table := range tableUpdates.Updates {
+				tables = append(tables, table)
+			}
+			sort.Strings(tables)
+			log.Printf("libovsdb: recovered from panic in %s handler for tables %v: %v", event, tables, r)
+		}
+	}()
+	f()
+}
+
+// jsonCodec is the JSON-RPC codec of rpc2, except that the params of the
+// handlers taking a []json.RawMessage are left undecoded. The rpc2 codec decodes
+// the params into interface{} values, where the numbers become float64 and lose
+// their precision above 2^53 before the handler can decode them with UseNumber
+type jsonCodec struct {
+	rpc2.Codec
+}
+
+// rawParams is the number of params decoded as raw JSON values, which is the
+// number of params of the update notification
+const rawParams = 2
+
+func newJSONCodec(conn io.ReadWriteCloser) rpc2.Codec {
+	return &jsonCodec{jsonrpc.NewJSONCodec(conn)}
+}
+
+// ReadRequestBody decodes the params into args. The rpc2 codec unmarshals them
+// as is into a *[]interface{}, and encoding/json decodes the elements of the
+// slice that hold a pointer into the value they point to
+func (c *jsonCodec) ReadRequestBody(args interface{}) error {
+	params, ok := args.(*[]json.RawMessage)
+	if !ok {
+		return c.Codec.ReadRequestBody(args)
+	}
+	raw := make([]json.RawMessage, rawParams)
+	elems := make([]interface{}, len(raw))
+	for i := range raw {
+		elems[i] = &raw[i]
+	}
+	if err := c.Codec.ReadRequestBody(&elems); err != nil {
+		return err
+	}
+	if len(elems) > len(raw) {
+		return fmt.Errorf("jsonrpc: %d params, expected at most %d", len(elems), len(raw))
+	}
+	*params = raw[:len(elems)]
 	return nil
 }
 
 // RFC 7047 : Update Notification Section 4.1.6
 // Processing "params": [<json-value>, <table-updates>]
-func update(client *rpc2.Client, params []interface{}, _ *interface{}) error {
+// The params are received as raw JSON so that the numbers of the rows are
+// decoded with UseNumber and keep their precision
+func update(client *rpc2.Client, params []json.RawMessage, _ *interface{}) error {
 	if len(params) < 2 {
 		return errors.New("Invalid Update message")
 	}
-	// Ignore params[0] as we dont use the <json-value> currently for comparison
-
-	raw, ok := params[1].(map[string]interface{})
-	if !ok {
-		return errors.New("Invalid Update message")
+	// params[0] is the <json-value> that identifies the monitor
+	var jsonContext interface{}
+	if err := json.Unmarshal(params[0], &jsonContext); err != nil {
+		return err
 	}
-	var rowUpdates map[string]map[string]RowUpdate
 
-	b, err := json.Marshal(raw)
-	if err != nil {
+	var rowUpdates map[string]map[string]RowUpdate
+	if err := json.Unmarshal(params[1], &rowUpdates); err != nil {
 		return err
 	}
-	err = json.Unmarshal(b, &rowUpdates)
-	if err != nil {
-		return err
+	if rowUpdates == nil {
+		return errors.New("Invalid Update message")
 	}
 
 	// Update the local DB cache with the tableUpdates
@@ -216,10 +490,28 @@ func update(client *rpc2.Client, params []interface{}, _ *interface{}) error {
 	connectionsMutex.RLock()
 	defer connectionsMutex.RUnlock()
 	if _, ok := connections[client]; ok {
+		// The monitor is looked up by the raw <json-value>, which is encoded
+		// like the ones of the monitors issued by the client
+		m, ok := connections[client].getMonitor(params[0])
+		if !ok && connections[client].isCancelled(params[0]) {
+			return nil
+		}
+		if metrics := connections[client].metricsObserver(); metrics != nil {
+			for table, tableUpdate := range tableUpdates.Updates {
+				metrics.ObserveUpdate(table, len(tableUpdate.Rows))
+			}
+		}
+		// Monitors with their own handler do not notify the registered ones.
+		// The updates of the monitors the client does not know, e.g: issued
+		// with Call, are delivered to the registered handlers
+		if ok && m.handler != nil {
+			notify("update", &tableUpdates, func() { m.handler.Update(jsonContext, tableUpdates) })
+			return nil
+		}
 		connections[client].handlersMutex.Lock()
 		defer connections[client].handlersMutex.Unlock()
 		for _, handler := range connections[client].handlers {
-			handler.Update(params[0], tableUpdates)
+			notify("update", &tableUpdates, func() { handler.Update(jsonContext, tableUpdates) })
 		}
 	}
 
@@ -228,22 +520,70 @@ func update(client *rpc2.Client, params []interface{}, _ *interface{}) error {
 
 // GetSchema returns the schema in use for the provided database name
 // RFC 7047 : get_schema
-func (ovs OvsdbClient) GetSchema(dbName string) (*DatabaseSchema, error) {
+func (ovs *OvsdbClient) GetSchema(dbName string) (*DatabaseSchema, error) {
 	args := NewGetSchemaArgs(dbName)
 	var reply DatabaseSchema
-	err := ovs.rpcClient.Call("get_schema", args, &reply)
+	err := ovs.call("get_schema", args, &reply)
 	if err != nil {
 		return nil, err
 	}
-	ovs.Schema[dbName] = reply
+	ovs.setSchema(dbName, reply)
 	return &reply, err
 }
 
+// setSchema stores the schema of a database and its NativeAPI. The NativeAPI
+// shares the tables of the schema, which are never modified: GetSchema decodes
+// a new schema every time, so replacing it does not affect the NativeAPIs
+// already in use
+func (ovs *OvsdbClient) setSchema(database string, schema DatabaseSchema) {
+	ovs.schemaMutex.Lock()
+	defer ovs.schemaMutex.Unlock()
+	ovs.schemas[database] = schema
+	ovs.apis[database] = NewNativeAPI(&schema)
+}
+
+// SchemaFor returns the schema of a database, as last fetched by GetSchema.
+// It is safe to call concurrently with GetSchema. The returned schema shares
+// its tables with the client, so they must not be modified
+func (ovs *OvsdbClient) SchemaFor(database string) (DatabaseSchema, bool) {
+	ovs.schemaMutex.RLock()
+	defer ovs.schemaMutex.RUnlock()
+	schema, ok := ovs.schemas[database]
+	return schema, ok
+}
+
+// APIFor returns the NativeAPI of a database, built from the schema last
+// fetched by GetSchema. It is safe to call concurrently with GetSchema
+func (ovs *OvsdbClient) APIFor(database string) (NativeAPI, bool) {
+	ovs.schemaMutex.RLock()
+	defer ovs.schemaMutex.RUnlock()
+	api, ok := ovs.apis[database]
+	return api, ok
+}
+
+// HasTable returns whether the schema of a database, as last fetched by
+// GetSchema, has the table. It is false for a database whose schema was not
+// fetched
+func (ovs *OvsdbClient) HasTable(database, table string) bool {
+	schema, ok := ovs.SchemaFor(database)
+	return ok && schema.HasTable(table)
+}
+
+// Columns returns the sorted names of the columns of a table from the schema of
+// a database, as last fetched by GetSchema
+func (ovs *OvsdbClient) Columns(database, table string) ([]string, error) {
+	schema, ok := ovs.SchemaFor(database)
+	if !ok {
+		return nil, fmt.Errorf("invalid Database %q Schema", database)
+	}
+	return schema.Columns(table)
+}
+
 // ListDbs returns the list of databases on the server
 // RFC 7047 : list_dbs
-func (ovs OvsdbClient) ListDbs() ([]string, error) {
+func (ovs *OvsdbClient) ListDbs() ([]string, error) {
 	var dbs []string
-	err := ovs.rpcClient.Call("list_dbs", nil, &dbs)
+	err := ovs.call("list_dbs", nil, &dbs)
 	if err != nil {
 		return nil, fmt.Errorf("ListDbs failure - %v", err)
 	}
@@ -252,9 +592,73 @@ func (ovs OvsdbClient) ListDbs() ([]string, error) {
 
 // Transact performs the provided Operation's on the database
 // RFC 7047 : transact
-func (ovs OvsdbClient) Transact(database string, operation ...Operation) ([]OperationResult, error) {
+// It is safe to call Transact (and the other RPC methods) from multiple
+// goroutines: every request gets its own id and is matched to its own reply.
+// The returned error is only set when the request as a whole failed: an
+// *RPCError if the server rejected it, ErrNotConnected if the client was not
+// connected when it was called, ErrConnectionClosed or ErrTimeout.
+// When the request succeeds, the failure of an operation is reported in the
+// Error of its OperationResult instead, except for a clustered database server
+// that is not the leader: ErrNotLeader is then returned along with the results
+func (ovs *OvsdbClient) Transact(database string, operation ...Operation) ([]OperationResult, error) {
 	var reply []OperationResult
-	db, ok := ovs.Schema[database]
+	operation, commented := ovs.commentOperations(operation)
+	args, err := ovs.transactArgs(database, operation...)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	err = ovs.call("transact", args, &reply)
+	if err != nil {
+		ovs.observeTransaction(start, err)
+		return nil, err
+	}
+	reply = uncommentResults(reply, commented)
+	err = notLeaderError(reply)
+	ovs.observeTransaction(start, err)
+	return reply, err
+}
+
+// SetTransactionComment sets a function returning the comment of the
+// transactions sent by Transact and TransactAsync (e.g: the name of the
+// application and the id of the request it is serving), which ovsdb-server
+// writes to its log. The comment operation is added in front of the operations
+// of each transaction and its result is removed from the returned ones. No
+// comment is added when the function returns an empty string, or when it is
+// nil, the default
+func (ovs *OvsdbClient) SetTransactionComment(comment func() string) {
+	ovs.stateMutex.Lock()
+	defer ovs.stateMutex.Unlock()
+	ovs.transactionComment = comment
+}
+
+// commentOperations returns the operations of a transaction preceded by the
+// comment operation, if there is a comment, and whether it was added
+func (ovs *OvsdbClient) commentOperations(operation []Operation) ([]Operation, bool) {
+	ovs.stateMutex.RLock()
+	transactionComment := ovs.transactionComment
+	ovs.stateMutex.RUnlock()
+	if transactionComment == nil {
+		return operation, false
+	}
+	comment := transactionComment()
+	if comment == "" {
+		return operation, false
+	}
+	return append([]Operation{NewCommentOperation(comment)}, operation...), true
+}
+
+// uncommentResults removes the result of the comment operation added by
+// commentOperations
+func uncommentResults(results []OperationResult, commented bool) []OperationResult {
+	if !commented || len(results) == 0 {
+		return results
+	}
+	return results[1:]
+}
+
+func (ovs *OvsdbClient) transactArgs(database string, operation ...Operation) ([]interface{}, error) {
+	db, ok := ovs.SchemaFor(database)
 	if !ok {
 		return nil, fmt.Errorf("invalid Database %q Schema", database)
 	}
@@ -262,30 +666,209 @@ func (ovs OvsdbClient) Transact(database string, operation ...Operation) ([]Oper
 	if ok := db.validateOperations(operation...); !ok {
 		return nil, errors.New("Validation failed for the operation")
 	}
+	return NewTransactArgs(database, operation...), nil
+}
+
+// TransactResult is the outcome of a transaction sent with TransactAsync
+type TransactResult struct {
+	Results []OperationResult
+	Err     error
+}
 
-	args := NewTransactArgs(database, operation...)
-	err := ovs.rpcClient.Call("transact", args, &reply)
+// TransactAsync sends a transaction like Transact but does not wait for its reply,
+// which is delivered on the returned channel. This allows pipelining many
+// transactions over the connection instead of waiting a round trip for each one.
+// Transactions are sent in the order TransactAsync is called and ovsdb-server
+// processes the requests of a connection in the order it receives them; each
+// transaction is still atomic on its own. The errors delivered are the ones of
+// Transact, including ErrTimeout once the request timeout expires
+func (ovs *OvsdbClient) TransactAsync(database string, operation ...Operation) <-chan TransactResult {
+	result := make(chan TransactResult, 1)
+	operation, commented := ovs.commentOperations(operation)
+	args, err := ovs.transactArgs(database, operation...)
+	if err != nil {
+		result <- TransactResult{Err: err}
+		return result
+	}
+	if err := ovs.checkConnected(); err != nil {
+		result <- TransactResult{Err: err}
+		return result
+	}
+	start := time.Now()
+	done := ovs.startRequest()
+	timeout := ovs.getRequestTimeout()
+	// Like in callContext, the reply is read into a buffer owned by the call
+	var raw json.RawMessage
+	call := ovs.rpcClient.Go("transact", args, &raw, make(chan *rpc2.Call, 1))
+	go func() {
+		var reply []OperationResult
+		err := ovs.waitReply(context.Background(), "transact", call, &raw, &reply, timeout)
+		done()
+		if err != nil {
+			ovs.observeTransaction(start, err)
+			result <- TransactResult{Err: err}
+			return
+		}
+		reply = uncommentResults(reply, commented)
+		err = notLeaderError(reply)
+		ovs.observeTransaction(start, err)
+		result <- TransactResult{Results: reply, Err: err}
+	}()
+	return result
+}
+
+// RetryPolicy defines when and how often TransactWithRetry retries a transaction
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times the transaction is sent
+	MaxAttempts int
+	// Interval is the time waited between two attempts
+	Interval time.Duration
+	// RetryableErrors are the errors worth retrying (e.g: "not leader"). They are
+	// matched against the error of the transact request and the errors of the
+	// operations
+	RetryableErrors []string
+}
+
+func (policy RetryPolicy) isRetryable(results []OperationResult, err error) bool {
+	for _, retryable := range policy.RetryableErrors {
+		if err != nil && strings.Contains(err.Error(), retryable) {
+			return true
+		}
+		for _, result := range results {
+			if result.Error == retryable {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TransactWithRetry performs the provided Operation's on the database like Transact,
+// sending them again as long as they fail with one of the retryable errors of the
+// policy. The operations must therefore be idempotent. It returns the results of
+// the last attempt, or the context error if it is done before the next attempt
+func (ovs *OvsdbClient) TransactWithRetry(ctx context.Context, database string, policy RetryPolicy, operation ...Operation) ([]OperationResult, error) {
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		results, err := ovs.Transact(database, operation...)
+		if attempt >= policy.MaxAttempts || !policy.isRetryable(results, err) {
+			return results, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(policy.Interval):
+		}
+	}
+}
+
+// Select is a convenience method that runs a single select operation and returns
+// the rows it matched as native type data maps (see NativeAPI.GetResultData).
+// Only the given columns are returned, or every column if columns is empty.
+// Conditions are typically built with NativeAPI.NewCondition; with none, every
+// row of the table is selected. An empty slice is returned when no row matches
+func (ovs *OvsdbClient) Select(database, table string, columns []string, conditions ...[]interface{}) ([]map[string]interface{}, error) {
+	api, ok := ovs.APIFor(database)
+	if !ok {
+		return nil, fmt.Errorf("invalid Database %q Schema", database)
+	}
+	where := make([]interface{}, 0, len(conditions))
+	for _, condition := range conditions {
+		where = append(where, condition)
+	}
+	results, err := ovs.Transact(database, Operation{
+		Op:      OperationSelect,
+		Table:   table,
+		Columns: columns,
+		Where:   where,
+	})
 	if err != nil {
 		return nil, err
 	}
-	return reply, nil
+	if len(results) == 0 {
+		return nil, errors.New("no result for the select operation")
+	}
+	if results[0].Error != "" {
+		return nil, fmt.Errorf("select failed: %s: %s", results[0].Error, results[0].Details)
+	}
+	return api.GetResultData(table, results[0])
 }
 
 // MonitorAll is a convenience method to monitor every table/column
-func (ovs OvsdbClient) MonitorAll(database string, jsonContext interface{}) (*TableUpdates, error) {
-	schema, ok := ovs.Schema[database]
+func (ovs *OvsdbClient) MonitorAll(database string, jsonContext interface{}) (*TableUpdates, error) {
+	return ovs.MonitorAllContext(context.Background(), database, jsonContext)
+}
+
+// MonitorAllContext is like MonitorAll but gives up waiting for the initial
+// contents of the database once ctx is done, returning the context error.
+// The monitor is then cancelled, so that no update is received for it, as it
+// is when the request timeout expires. Until the cancellation is sent to the
+// server, a monitor with the same jsonContext cannot be issued again
+func (ovs *OvsdbClient) MonitorAllContext(ctx context.Context, database string, jsonContext interface{}) (*TableUpdates, error) {
+	schema, ok := ovs.SchemaFor(database)
 	if !ok {
 		return nil, fmt.Errorf("invalid Database %q Schema", database)
 	}
 
-	requests := make(map[string]MonitorRequest)
-	for table, tableSchema := range schema.Tables {
-		var columns []string
+	tables := make([]string, 0, len(schema.Tables))
+	for table := range schema.Tables {
+		tables = append(tables, table)
+	}
+	return ovs.monitorTables(ctx, database, jsonContext, tables...)
+}
+
+// MonitorTables is a convenience method to monitor every column of the provided
+// tables. The other tables of the database are not monitored
+func (ovs *OvsdbClient) MonitorTables(database string, jsonContext interface{}, tables ...string) (*TableUpdates, error) {
+	return ovs.monitorTables(context.Background(), database, jsonContext, tables...)
+}
+
+func (ovs *OvsdbClient) monitorTables(ctx context.Context, database string, jsonContext interface{}, tables ...string) (*TableUpdates, error) {
+	schema, ok := ovs.SchemaFor(database)
+	if !ok {
+		return nil, fmt.Errorf("invalid Database %q Schema", database)
+	}
+
+	columns := make(map[string][]string, len(tables))
+	for _, table := range tables {
+		tableSchema, ok := schema.Tables[table]
+		if !ok {
+			return nil, NewErrNoTable(table)
+		}
 		for column := range tableSchema.Columns {
-			columns = append(columns, column)
+			columns[table] = append(columns[table], column)
+		}
+	}
+	return ovs.monitorColumns(ctx, database, jsonContext, columns)
+}
+
+// MonitorColumns is a convenience method to monitor only the provided columns
+// of each table. Tables that are not present in the columns map are not monitored
+func (ovs *OvsdbClient) MonitorColumns(database string, jsonContext interface{}, columns map[string][]string) (*TableUpdates, error) {
+	return ovs.monitorColumns(context.Background(), database, jsonContext, columns)
+}
+
+func (ovs *OvsdbClient) monitorColumns(ctx context.Context, database string, jsonContext interface{}, columns map[string][]string) (*TableUpdates, error) {
+	schema, ok := ovs.SchemaFor(database)
+	if !ok {
+		return nil, fmt.Errorf("invalid Database %q Schema", database)
+	}
+
+	requests := make(map[string]MonitorRequest)
+	for table, tableColumns := range columns {
+		tableSchema, ok := schema.Tables[table]
+		if !ok {
+			return nil, NewErrNoTable(table)
+		}
+		for _, column := range tableColumns {
+			if _, ok := tableSchema.Columns[column]; !ok {
+				return nil, fmt.Errorf("Column not found in schema %s", column)
+			}
 		}
 		requests[table] = MonitorRequest{
-			Columns: columns,
+			Columns: tableColumns,
 			Select: MonitorSelect{
 				Initial: true,
 				Insert:  true,
@@ -293,43 +876,200 @@ func (ovs OvsdbClient) MonitorAll(database string, jsonContext interface{}) (*Ta
 				Modify:  true,
 			}}
 	}
-	return ovs.Monitor(database, jsonContext, requests)
+	return ovs.monitor(ctx, database, jsonContext, requests, nil)
+}
+
+// monitorKey returns the key that identifies a monitor by its <json-value>.
+// The json-value can be any JSON value, so its encoding is used. The value is
+// decoded and encoded again, like the one of an update notification is, so
+// that e.g: a struct and the object it is received as have the same key
+func monitorKey(jsonContext interface{}) (string, error) {
+	b, err := json.Marshal(jsonContext)
+	if err != nil {
+		return "", err
+	}
+	var value interface{}
+	if err := unmarshalUseNumber(b, &value); err != nil {
+		return "", err
+	}
+	b, err = json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// getMonitor returns the active monitor with the provided <json-value>
+func (ovs *OvsdbClient) getMonitor(jsonContext interface{}) (*monitor, bool) {
+	key, err := monitorKey(jsonContext)
+	if err != nil {
+		return nil, false
+	}
+	ovs.monitorsMutex.Lock()
+	defer ovs.monitorsMutex.Unlock()
+	m, ok := ovs.monitors[key]
+	return m, ok
+}
+
+// isCancelled returns whether the monitor with the provided <json-value> was
+// cancelled by the client
+func (ovs *OvsdbClient) isCancelled(jsonContext interface{}) bool {
+	key, err := monitorKey(jsonContext)
+	if err != nil {
+		return false
+	}
+	ovs.monitorsMutex.Lock()
+	defer ovs.monitorsMutex.Unlock()
+	_, ok := ovs.cancelled[key]
+	return ok
+}
+
+// cancelMonitor cancels a monitor whose request was not answered in time. The
+// server processes the requests in order, so sending monitor_cancel right away
+// is enough, and its reply does not matter. The request is sent in the
+// background as writing it blocks until the server reads it, and the monitor
+// cannot be issued again until it is sent, so that the server does not get it
+// before the cancellation
+func (ovs *OvsdbClient) cancelMonitor(key string, jsonContext interface{}) {
+	ovs.forgetMonitor(key)
+	ovs.monitorsMutex.Lock()
+	ovs.cancelling[key] = struct{}{}
+	ovs.monitorsMutex.Unlock()
+	go func() {
+		ovs.rpcClient.Go("monitor_cancel", NewMonitorCancelArgs(jsonContext), nil, make(chan *rpc2.Call, 1))
+		ovs.monitorsMutex.Lock()
+		delete(ovs.cancelling, key)
+		ovs.monitorsMutex.Unlock()
+	}()
+}
+
+// forgetMonitor removes the monitor with the provided key from the active ones
+// and drops its updates from now on
+func (ovs *OvsdbClient) forgetMonitor(key string) {
+	ovs.monitorsMutex.Lock()
+	defer ovs.monitorsMutex.Unlock()
+	delete(ovs.monitors, key)
+	ovs.cancelled[key] = struct{}{}
 }
 
 // MonitorCancel will request cancel a previously issued monitor request
+// Once cancelled, no more updates are delivered for the monitor
 // RFC 7047 : monitor_cancel
-func (ovs OvsdbClient) MonitorCancel(jsonContext interface{}) error {
+func (ovs *OvsdbClient) MonitorCancel(jsonContext interface{}) error {
 	var reply OperationResult
 
+	key, err := monitorKey(jsonContext)
+	if err != nil {
+		return err
+	}
+	ovs.monitorsMutex.Lock()
+	_, ok := ovs.monitors[key]
+	ovs.monitorsMutex.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown monitor %s", key)
+	}
+
 	args := NewMonitorCancelArgs(jsonContext)
 
-	err := ovs.rpcClient.Call("monitor_cancel", args, &reply)
+	err = ovs.call("monitor_cancel", args, &reply)
 	if err != nil {
 		return err
 	}
 	if reply.Error != "" {
 		return fmt.Errorf("Error while executing transaction: %s", reply.Error)
 	}
+	ovs.forgetMonitor(key)
 	return nil
 }
 
 // Monitor will provide updates for a given table/column
 // RFC 7047 : monitor
-func (ovs OvsdbClient) Monitor(database string, jsonContext interface{}, requests map[string]MonitorRequest) (*TableUpdates, error) {
+// The Select of each request chooses which kinds of updates (initial, insert,
+// delete, modify) are sent; its zero value selects all of them
+func (ovs *OvsdbClient) Monitor(database string, jsonContext interface{}, requests map[string]MonitorRequest) (*TableUpdates, error) {
+	return ovs.MonitorWithHandler(database, jsonContext, requests, nil)
+}
+
+// MonitorWithHandler is like Monitor but the updates of this monitor are only
+// delivered to the provided handler instead of the registered ones.
+// This allows running several monitors on the same connection, each of them
+// identified by its jsonContext, and processing their updates separately
+func (ovs *OvsdbClient) MonitorWithHandler(database string, jsonContext interface{}, requests map[string]MonitorRequest, handler NotificationHandler) (*TableUpdates, error) {
+	return ovs.monitor(context.Background(), database, jsonContext, requests, handler)
+}
+
+func (ovs *OvsdbClient) monitor(ctx context.Context, database string, jsonContext interface{}, requests map[string]MonitorRequest, handler NotificationHandler) (*TableUpdates, error) {
 	var reply TableUpdates
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// The monitor is registered before issuing the request so that no update
+	// that follows the reply is dropped
+	key, err := monitorKey(jsonContext)
+	if err != nil {
+		return nil, err
+	}
+	ovs.monitorsMutex.Lock()
+	if _, ok := ovs.monitors[key]; ok {
+		ovs.monitorsMutex.Unlock()
+		return nil, fmt.Errorf("monitor %s already exists", key)
+	}
+	if _, ok := ovs.cancelling[key]; ok {
+		ovs.monitorsMutex.Unlock()
+		return nil, fmt.Errorf("monitor %s is being cancelled", key)
+	}
+	ovs.monitors[key] = &monitor{
+		database: database,
+		requests: requests,
+		handler:  handler,
+	}
+	delete(ovs.cancelled, key)
+	ovs.monitorsMutex.Unlock()
 
 	args := NewMonitorArgs(database, jsonContext, requests)
 
 	// This totally sucks. Refer to golang JSON issue #6213
 	var response map[string]map[string]RowUpdate
-	err := ovs.rpcClient.Call("monitor", args, &response)
+	err = ovs.callContext(ctx, "monitor", args, &response)
 	reply = getTableUpdatesFromRawUnmarshal(response)
 	if err != nil {
+		if err != ctx.Err() && err != ErrTimeout {
+			ovs.monitorsMutex.Lock()
+			delete(ovs.monitors, key)
+			ovs.monitorsMutex.Unlock()
+			return nil, err
+		}
+		// The server may still set the monitor up
+		ovs.cancelMonitor(key, jsonContext)
 		return nil, err
 	}
 	return &reply, err
 }
 
+// UpdateMonitor replaces the requests of an active monitor, e.g: to monitor
+// more tables or columns at runtime. The monitor is cancelled and issued again
+// with the same jsonContext, database and handler, and the initial contents of
+// the new requests are returned so that the caller can resynchronize its data.
+// If the new requests are rejected, the previous ones are restored
+func (ovs *OvsdbClient) UpdateMonitor(jsonContext interface{}, requests map[string]MonitorRequest) (*TableUpdates, error) {
+	m, ok := ovs.getMonitor(jsonContext)
+	if !ok {
+		return nil, fmt.Errorf("unknown monitor %v", jsonContext)
+	}
+	if err := ovs.MonitorCancel(jsonContext); err != nil {
+		return nil, err
+	}
+	updates, err := ovs.MonitorWithHandler(m.database, jsonContext, requests, m.handler)
+	if err != nil {
+		if _, restoreErr := ovs.MonitorWithHandler(m.database, jsonContext, m.requests, m.handler); restoreErr != nil {
+			return nil, fmt.Errorf("%v (previous monitor not restored: %v)", err, restoreErr)
+		}
+		return nil, err
+	}
+	return updates, nil
+}
+
 func getTableUpdatesFromRawUnmarshal(raw map[string]map[string]RowUpdate) TableUpdates {
 	var tableUpdates TableUpdates
 	tableUpdates.Updates = make(map[string]TableUpdate)
@@ -343,25 +1083,382 @@ func getTableUpdatesFromRawUnmarshal(raw map[string]map[string]RowUpdate) TableU
 func clearConnection(c *rpc2.Client) {
 	connectionsMutex.Lock()
 	defer connectionsMutex.Unlock()
-	if _, ok := connections[c]; ok {
-		for _, handler := range connections[c].handlers {
+	if ovs, ok := connections[c]; ok {
+		ovs.handlersMutex.Lock()
+		handlers := append([]NotificationHandler(nil), ovs.handlers...)
+		ovs.handlersMutex.Unlock()
+		for _, handler := range handlers {
 			if handler != nil {
-				handler.Disconnected(connections[c])
+				notify("disconnected", nil, func() { handler.Disconnected(connections[c]) })
 			}
 		}
 	}
 	delete(connections, c)
 }
 
-func handleDisconnectNotification(c *rpc2.Client) {
-	disconnected := c.DisconnectNotify()
+// handleDisconnectNotification tears the client down once the RPC reader
+// goroutine has terminated, whether because of Close or of a connection error.
+// The reader fails the pending requests before signaling it, and the handlers
+// are notified only once since the signal is a closed channel
+func (ovs *OvsdbClient) handleDisconnectNotification() {
+	disconnected := ovs.rpcClient.DisconnectNotify()
 	select {
 	case <-disconnected:
-		clearConnection(c)
+		// rpc2 only closes the connection on Close, not when its reader fails
+		if ovs.conn != nil {
+			ovs.conn.Close()
+		}
+		ovs.setConnected(false)
+		clearConnection(ovs.rpcClient)
 	}
 }
 
+// setConnected updates the connection state and runs the OnConnect or
+// OnDisconnect callbacks accordingly
+func (ovs *OvsdbClient) setConnected(connected bool) {
+	ovs.stateMutex.Lock()
+	ovs.connected = connected
+	var callbacks []func(*OvsdbClient)
+	if connected {
+		callbacks = append(callbacks, ovs.onConnect...)
+	} else {
+		callbacks = append(callbacks, ovs.onDisconnect...)
+	}
+	ovs.stateMutex.Unlock()
+	for _, cb := range callbacks {
+		cb(ovs)
+	}
+}
+
+// Connected returns whether the client is currently connected to the server
+func (ovs *OvsdbClient) Connected() bool {
+	ovs.stateMutex.RLock()
+	defer ovs.stateMutex.RUnlock()
+	return ovs.connected
+}
+
+// OnConnect registers a callback to be invoked when the client gets connected.
+// If the client is already connected, the callback is invoked right away
+func (ovs *OvsdbClient) OnConnect(cb func(*OvsdbClient)) {
+	ovs.stateMutex.Lock()
+	ovs.onConnect = append(ovs.onConnect, cb)
+	connected := ovs.connected
+	ovs.stateMutex.Unlock()
+	if connected {
+		cb(ovs)
+	}
+}
+
+// OnDisconnect registers a callback to be invoked when the client loses its
+// connection to the server. Unlike the Disconnected notification, it does not
+// require implementing a NotificationHandler
+func (ovs *OvsdbClient) OnDisconnect(cb func(*OvsdbClient)) {
+	ovs.stateMutex.Lock()
+	defer ovs.stateMutex.Unlock()
+	ovs.onDisconnect = append(ovs.onDisconnect, cb)
+}
+
+// SetInactivityProbe enables sending an echo request to the server every
+// interval. If the server does not read the request and reply to it within
+// the next interval, the connection is considered dead and it is closed.
+// An interval of zero disables the probe, which is the default
+func (ovs *OvsdbClient) SetInactivityProbe(interval time.Duration) {
+	ovs.stateMutex.Lock()
+	defer ovs.stateMutex.Unlock()
+	if ovs.probeStop != nil {
+		close(ovs.probeStop)
+		ovs.probeStop = nil
+	}
+	if interval <= 0 {
+		return
+	}
+	ovs.probeStop = make(chan struct{})
+	go ovs.inactivityProbe(interval, ovs.probeStop)
+}
+
+func (ovs *OvsdbClient) inactivityProbe(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	disconnected := ovs.rpcClient.DisconnectNotify()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-disconnected:
+			return
+		case <-ticker.C:
+		}
+		// The request is sent in the background, as writing it blocks while
+		// the server does not read: not reading it within the interval is a
+		// probe failure as well
+		var reply []interface{}
+		done := make(chan *rpc2.Call, 1)
+		timeout := time.NewTimer(interval)
+		go ovs.rpcClient.Go("echo", []interface{}{"libovsdb echo"}, &reply, done)
+		select {
+		case <-done:
+			timeout.Stop()
+		case <-timeout.C:
+			ovs.Close()
+			return
+		case <-stop:
+			timeout.Stop()
+			return
+		case <-disconnected:
+			timeout.Stop()
+			return
+		}
+	}
+}
+
+// ErrConnectionClosed is returned by requests that could not complete because
+// the connection to the server was closed
+var ErrConnectionClosed = errors.New("connection closed")
+
+// ErrNotConnected is returned right away by the requests made while the client
+// is not connected, i.e: after Close or once the connection was lost
+var ErrNotConnected = errors.New("not connected")
+
+// ErrNotLeader is returned by the requests that a server of a clustered database
+// rejected because it is not the leader of the cluster, whether it failed the
+// whole request or the transaction. They can be sent again to another server
+var ErrNotLeader = errors.New("not leader")
+
+// notLeaderError returns ErrNotLeader if the results of a transaction tell
+// that the server is not the leader of its cluster
+func notLeaderError(results []OperationResult) error {
+	for _, result := range results {
+		if result.Error == ErrNotLeader.Error() {
+			return ErrNotLeader
+		}
+	}
+	return nil
+}
+
+// checkConnected returns ErrNotConnected if requests cannot be sent
+func (ovs *OvsdbClient) checkConnected() error {
+	ovs.stateMutex.RLock()
+	defer ovs.stateMutex.RUnlock()
+	if ovs.closed || !ovs.connected {
+		return ErrNotConnected
+	}
+	return nil
+}
+
+// RPCError is returned when a JSON-RPC request as a whole fails, either because
+// the server replied with an error (e.g: an unknown method or a malformed
+// request) or because its reply could not be decoded. It is not used for the
+// failures of the operations of a successful transact request, which are
+// reported in the Error of their OperationResult
+type RPCError struct {
+	// Method is the JSON-RPC method of the failed request
+	Method string
+	// Message is the error returned by the server or the decoding error
+	Message string
+	// FromServer tells whether the error was returned by the server
+	FromServer bool
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s request failed: %s", e.Method, e.Message)
+}
+
+// ErrTimeout is returned by requests whose reply was not received within the
+// timeout set with SetRequestTimeout
+var ErrTimeout = errors.New("request timed out")
+
+// maxTimeouts is the number of requests in a row that time out after which the
+// server is considered wedged and the connection is closed
+const maxTimeouts = 3
+
+// SetRequestTimeout sets the maximum time the requests (Transact, Monitor,
+// GetSchema, ...) wait for a reply before failing with ErrTimeout.
+// The RPC client keeps a timed out request pending until its reply arrives, so
+// once 3 requests in a row time out without any reply in between, the
+// connection is closed to release them, as if Close was called.
+// A timeout of zero, the default, waits forever
+func (ovs *OvsdbClient) SetRequestTimeout(timeout time.Duration) {
+	ovs.stateMutex.Lock()
+	defer ovs.stateMutex.Unlock()
+	ovs.requestTimeout = timeout
+}
+
+// MetricsObserver receives measurements of the activity of the client, e.g: to
+// export them to a metrics system. Its methods are called synchronously from the
+// goroutines of the requests and notifications, so they must not block
+type MetricsObserver interface {
+	// ObserveTransaction is called once the reply of a transaction is received,
+	// or the request failed, with its round trip time and the request error
+	ObserveTransaction(duration time.Duration, err error)
+	// ObserveUpdate is called for each table of an update notification of an
+	// active monitor with the number of rows it updates
+	ObserveUpdate(table string, rows int)
+	// ObservePendingRequests is called whenever a request is sent or completed
+	// with the number of requests waiting for a reply
+	ObservePendingRequests(pending int)
+}
+
+// SetMetricsObserver sets the MetricsObserver notified of the activity of the
+// client. A nil observer, the default, disables the notifications
+func (ovs *OvsdbClient) SetMetricsObserver(observer MetricsObserver) {
+	ovs.stateMutex.Lock()
+	defer ovs.stateMutex.Unlock()
+	ovs.metrics = observer
+}
+
+func (ovs *OvsdbClient) metricsObserver() MetricsObserver {
+	ovs.stateMutex.RLock()
+	defer ovs.stateMutex.RUnlock()
+	return ovs.metrics
+}
+
+// startRequest accounts for a request waiting for a reply and returns the
+// function to call once it completes
+func (ovs *OvsdbClient) startRequest() func() {
+	pending := atomic.AddInt32(&ovs.pendingRequests, 1)
+	if metrics := ovs.metricsObserver(); metrics != nil {
+		metrics.ObservePendingRequests(int(pending))
+	}
+	return func() {
+		pending := atomic.AddInt32(&ovs.pendingRequests, -1)
+		if metrics := ovs.metricsObserver(); metrics != nil {
+			metrics.ObservePendingRequests(int(pending))
+		}
+	}
+}
+
+// observeTransaction reports a transaction sent at start and its request error
+func (ovs *OvsdbClient) observeTransaction(start time.Time, err error) {
+	if metrics := ovs.metricsObserver(); metrics != nil {
+		metrics.ObserveTransaction(time.Since(start), err)
+	}
+}
+
+// Call performs a JSON-RPC request of any method, including the ones this library
+// does not wrap (e.g: server extensions), and unmarshals its result into reply.
+// The caller owns the types of args and reply: args is encoded as the params of
+// the request, so it is usually a slice, and reply must be a pointer.
+// Like the other requests, it honours the request timeout and fails with
+// ErrConnectionClosed when the connection goes away.
+// The updates of a monitor issued with Call are delivered to the registered
+// handlers, and the client does not track it, e.g: for MonitorCancel
+func (ovs *OvsdbClient) Call(method string, args interface{}, reply interface{}) error {
+	return ovs.call(method, args, reply)
+}
+
+// call performs a JSON-RPC request and waits for its reply. Requests made while
+// the client is not connected fail right away with ErrNotConnected, the ones
+// that are pending when the connection goes away with ErrConnectionClosed and
+// the ones exceeding the request timeout with ErrTimeout
+func (ovs *OvsdbClient) call(method string, args interface{}, reply interface{}) error {
+	return ovs.callContext(context.Background(), method, args, reply)
+}
+
+// callContext is like call but stops waiting for the reply, and returns the
+// context error, once ctx is done
+func (ovs *OvsdbClient) callContext(ctx context.Context, method string, args interface{}, reply interface{}) error {
+	if err := ovs.checkConnected(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := ovs.startRequest()
+	defer done()
+	timeout := ovs.getRequestTimeout()
+	if timeout <= 0 && ctx.Done() == nil {
+		err := ovs.rpcClient.Call(method, args, reply)
+		if err == nil {
+			return nil
+		}
+		return ovs.callError(method, err)
+	}
+
+	// The reply is first read into a buffer owned by the call, so a reply
+	// arriving after the timeout is dropped instead of being written to the
+	// caller's reply. rpc2 forgets the request once that late reply is read
+	// or the connection is closed, which waitReply does after maxTimeouts
+	var raw json.RawMessage
+	call := ovs.rpcClient.Go(method, args, &raw, make(chan *rpc2.Call, 1))
+	return ovs.waitReply(ctx, method, call, &raw, reply, timeout)
+}
+
+// getRequestTimeout returns the timeout set with SetRequestTimeout
+func (ovs *OvsdbClient) getRequestTimeout() time.Duration {
+	ovs.stateMutex.RLock()
+	defer ovs.stateMutex.RUnlock()
+	return ovs.requestTimeout
+}
+
+// waitReply waits for the reply of a call sent with its raw buffer as reply,
+// then decodes the buffer into reply. It gives up once the timeout, if any,
+// expires or ctx is done. After maxTimeouts timeouts in a row, the connection
+// is closed so that the calls left pending by rpc2 are released
+func (ovs *OvsdbClient) waitReply(ctx context.Context, method string, call *rpc2.Call, raw *json.RawMessage, reply interface{}, timeout time.Duration) error {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-call.Done:
+		atomic.StoreInt32(&ovs.timeouts, 0)
+	case <-expired:
+		if atomic.AddInt32(&ovs.timeouts, 1) >= maxTimeouts {
+			log.Printf("libovsdb: %d requests in a row timed out, closing the connection", maxTimeouts)
+			ovs.Close()
+		}
+		return ErrTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if call.Error != nil {
+		return ovs.callError(method, call.Error)
+	}
+	if len(*raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(*raw, reply); err != nil {
+		return &RPCError{Method: method, Message: err.Error()}
+	}
+	return nil
+}
+
+// callError maps the errors of the RPC client that mean the connection is gone
+// to ErrConnectionClosed, the "not leader" error of the server to ErrNotLeader
+// and the other ones to an RPCError
+func (ovs *OvsdbClient) callError(method string, err error) error {
+	if e, ok := err.(rpc2.ServerError); ok {
+		if string(e) == ErrNotLeader.Error() {
+			return ErrNotLeader
+		}
+		return &RPCError{Method: method, Message: string(e), FromServer: true}
+	}
+	ovs.stateMutex.RLock()
+	closed := ovs.closed
+	ovs.stateMutex.RUnlock()
+	if closed || err == rpc2.ErrShutdown || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrConnectionClosed
+	}
+	return &RPCError{Method: method, Message: err.Error()}
+}
+
 // Disconnect will close the OVSDB connection
-func (ovs OvsdbClient) Disconnect() {
+func (ovs *OvsdbClient) Disconnect() {
+	ovs.Close()
+}
+
+// Close closes the OVSDB connection. Requests waiting for a reply fail with
+// ErrConnectionClosed and the RPC reader goroutine terminates.
+// It is safe to call Close multiple times
+func (ovs *OvsdbClient) Close() {
+	ovs.stateMutex.Lock()
+	if ovs.closed {
+		ovs.stateMutex.Unlock()
+		return
+	}
+	ovs.closed = true
+	ovs.stateMutex.Unlock()
 	ovs.rpcClient.Close()
 }
@@ -0,0 +1,1364 @@
+package libovsdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rpc2"
+	"github.com/cenkalti/rpc2/jsonrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestClient returns an OvsdbClient connected through an in-process pipe to a
+// fake server that does not hold any database. The server side is returned so
+// tests can register additional methods and close the connection
+func newTestClient(t *testing.T, handlers map[string]interface{}) (*OvsdbClient, *rpc2.Client) {
+	return startTestClient(t, handlers, false)
+}
+
+// newBlockingTestClient is like newTestClient but, like ovsdb-server, its fake
+// server processes the requests one at a time and in order: a handler that
+// does not return blocks the following requests
+func newBlockingTestClient(t *testing.T, handlers map[string]interface{}) (*OvsdbClient, *rpc2.Client) {
+	return startTestClient(t, handlers, true)
+}
+
+func startTestClient(t *testing.T, handlers map[string]interface{}, blocking bool) (*OvsdbClient, *rpc2.Client) {
+	clientConn, serverConn := net.Pipe()
+	srv := rpc2.NewClientWithCodec(jsonrpc.NewJSONCodec(serverConn))
+	if blocking {
+		srv.SetBlocking(true)
+	}
+	srv.Handle("list_dbs", func(_ *rpc2.Client, _ []interface{}, reply *[]string) error {
+		*reply = []string{}
+		return nil
+	})
+	for method, handler := range handlers {
+		srv.Handle(method, handler)
+	}
+	go srv.Run()
+
+	ovs, err := ConnectWithConn(clientConn, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ovs, srv
+}
+
+func TestConnectionState(t *testing.T) {
+	ovs, srv := newTestClient(t, nil)
+	assert.True(t, ovs.Connected())
+
+	connected := make(chan bool, 1)
+	ovs.OnConnect(func(*OvsdbClient) {
+		connected <- true
+	})
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatal("OnConnect callback not invoked on a connected client")
+	}
+
+	disconnected := make(chan bool, 1)
+	ovs.OnDisconnect(func(c *OvsdbClient) {
+		disconnected <- c.Connected()
+	})
+	srv.Close()
+	select {
+	case state := <-disconnected:
+		assert.False(t, state)
+	case <-time.After(time.Second):
+		t.Fatal("OnDisconnect callback not invoked")
+	}
+	assert.False(t, ovs.Connected())
+}
+
+func TestCloseFailsPendingRequests(t *testing.T) {
+	received := make(chan bool)
+	release := make(chan bool)
+	defer close(release)
+	ovs, _ := newTestClient(t, map[string]interface{}{
+		// transact does not reply until the test is over
+		"transact": func(_ *rpc2.Client, _ []interface{}, _ *[]interface{}) error {
+			received <- true
+			<-release
+			return nil
+		},
+	})
+	ovs.setSchema("db", DatabaseSchema{Name: "db", Tables: map[string]TableSchema{}})
+
+	errs := make(chan error)
+	go func() {
+		_, err := ovs.Transact("db")
+		errs <- err
+	}()
+	<-received
+
+	ovs.Close()
+	select {
+	case err := <-errs:
+		assert.Equal(t, ErrConnectionClosed, err)
+	case <-time.After(time.Second):
+		t.Fatal("pending Transact did not return after Close")
+	}
+
+	// Closing twice must be harmless
+	ovs.Close()
+	ovs.Disconnect()
+
+	_, err := ovs.ListDbs()
+	assert.NotNil(t, err)
+	_, err = ovs.Transact("db")
+	assert.Equal(t, ErrNotConnected, err)
+}
+
+func TestTransactNotConnected(t *testing.T) {
+	ovs, srv := newTestClient(t, nil)
+	ovs.setSchema("db", DatabaseSchema{Name: "db", Tables: map[string]TableSchema{}})
+
+	srv.Close()
+	assert.Eventually(t, func() bool { return !ovs.Connected() }, time.Second, 10*time.Millisecond)
+
+	_, err := ovs.Transact("db")
+	assert.Equal(t, ErrNotConnected, err)
+	result := <-ovs.TransactAsync("db")
+	assert.Equal(t, ErrNotConnected, result.Err)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	release := make(chan bool)
+	defer close(release)
+	ovs, _ := newTestClient(t, map[string]interface{}{
+		// transact does not reply until the test is over
+		"transact": func(_ *rpc2.Client, _ []interface{}, _ *[]interface{}) error {
+			<-release
+			return nil
+		},
+	})
+	defer ovs.Close()
+	ovs.setSchema("db", DatabaseSchema{Name: "db", Tables: map[string]TableSchema{}})
+	ovs.SetRequestTimeout(50 * time.Millisecond)
+
+	start := time.Now()
+	_, err := ovs.Transact("db")
+	assert.Equal(t, ErrTimeout, err)
+	assert.True(t, time.Since(start) < time.Second)
+
+	// Requests answered in time are not affected
+	dbs, err := ovs.ListDbs()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, dbs)
+
+	ovs.SetRequestTimeout(0)
+	assert.True(t, ovs.Connected())
+}
+
+func TestRepeatedTimeoutsCloseConnection(t *testing.T) {
+	ovs, _ := newTestClient(t, map[string]interface{}{
+		// transact never replies: replying concurrently would race in the
+		// codec of the fake server
+		"transact": func(_ *rpc2.Client, _ []interface{}, _ *[]interface{}) error {
+			select {}
+		},
+	})
+	defer ovs.Close()
+	ovs.setSchema("db", DatabaseSchema{Name: "db", Tables: map[string]TableSchema{}})
+	ovs.SetRequestTimeout(20 * time.Millisecond)
+
+	// A reply in between resets the count of timeouts
+	for i := 0; i < maxTimeouts-1; i++ {
+		_, err := ovs.Transact("db")
+		assert.Equal(t, ErrTimeout, err)
+	}
+	_, err := ovs.ListDbs()
+	assert.Nil(t, err)
+	assert.True(t, ovs.Connected())
+
+	for i := 0; i < maxTimeouts; i++ {
+		_, err := ovs.Transact("db")
+		assert.Equal(t, ErrTimeout, err)
+	}
+	assert.Eventually(t, func() bool { return !ovs.Connected() }, time.Second, 10*time.Millisecond)
+	_, err = ovs.Transact("db")
+	assert.Equal(t, ErrNotConnected, err)
+}
+
+func TestTransactAsyncTimeout(t *testing.T) {
+	release := make(chan bool, 1)
+	ovs, _ := newBlockingTestClient(t, map[string]interface{}{
+		// transact does not reply, and the server reads nothing, until released
+		"transact": func(_ *rpc2.Client, _ []interface{}, reply *[]OperationResult) error {
+			<-release
+			*reply = []OperationResult{{Count: 1}}
+			return nil
+		},
+	})
+	defer ovs.Close()
+	setTestSchema(t, ovs)
+	ovs.SetRequestTimeout(50 * time.Millisecond)
+
+	start := time.Now()
+	r := <-ovs.TransactAsync("TestSchema")
+	assert.Equal(t, ErrTimeout, r.Err)
+	assert.Nil(t, r.Results)
+	assert.True(t, time.Since(start) < time.Second)
+	// The request is no longer accounted as pending
+	assert.Equal(t, int32(0), atomic.LoadInt32(&ovs.pendingRequests))
+
+	// The late reply is dropped and does not affect the next transaction
+	release <- true
+	release <- true
+	r = <-ovs.TransactAsync("TestSchema")
+	assert.Nil(t, r.Err)
+	assert.Equal(t, []OperationResult{{Count: 1}}, r.Results)
+}
+
+func TestCall(t *testing.T) {
+	ovs, _ := newTestClient(t, map[string]interface{}{
+		"get_server_id": func(_ *rpc2.Client, args []interface{}, reply *string) error {
+			*reply = fmt.Sprintf("server-%v", args[0])
+			return nil
+		},
+	})
+	defer ovs.Close()
+
+	var id string
+	assert.Nil(t, ovs.Call("get_server_id", []interface{}{"a"}, &id))
+	assert.Equal(t, "server-a", id)
+
+	err := ovs.Call("no_such_method", []interface{}{}, &id)
+	assert.NotNil(t, err)
+}
+
+func TestTransactRPCError(t *testing.T) {
+	ovs, _ := newTestClient(t, map[string]interface{}{
+		"transact": func(_ *rpc2.Client, _ []interface{}, _ *[]interface{}) error {
+			return fmt.Errorf("syntax error")
+		},
+	})
+	defer ovs.Close()
+	ovs.setSchema("db", DatabaseSchema{Name: "db", Tables: map[string]TableSchema{}})
+
+	_, err := ovs.Transact("db")
+	rpcErr, ok := err.(*RPCError)
+	if assert.True(t, ok, "unexpected error %v", err) {
+		assert.Equal(t, "transact", rpcErr.Method)
+		assert.Equal(t, "syntax error", rpcErr.Message)
+		assert.True(t, rpcErr.FromServer)
+	}
+
+	result := <-ovs.TransactAsync("db")
+	_, ok = result.Err.(*RPCError)
+	assert.True(t, ok, "unexpected error %v", result.Err)
+}
+
+// testMetrics records the observations of a MetricsObserver
+type testMetrics struct {
+	mutex        sync.Mutex
+	transactions []error
+	updates      map[string]int
+	pending      []int
+}
+
+func (m *testMetrics) ObserveTransaction(duration time.Duration, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.transactions = append(m.transactions, err)
+}
+
+func (m *testMetrics) ObserveUpdate(table string, rows int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.updates[table] += rows
+}
+
+func (m *testMetrics) ObservePendingRequests(pending int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.pending = append(m.pending, pending)
+}
+
+func TestMetricsObserver(t *testing.T) {
+	handlers := map[string]interface{}{
+		"transact": func(_ *rpc2.Client, args []interface{}, reply *[]interface{}) error {
+			if len(args) > 1 {
+				return fmt.Errorf("syntax error")
+			}
+			*reply = []interface{}{}
+			return nil
+		},
+	}
+	for method, handler := range emptyMonitorHandlers {
+		handlers[method] = handler
+	}
+	ovs, srv := newTestClient(t, handlers)
+	defer ovs.Close()
+	ovs.setSchema("db", DatabaseSchema{Name: "db", Tables: map[string]TableSchema{}})
+	metrics := &testMetrics{updates: make(map[string]int)}
+	ovs.SetMetricsObserver(metrics)
+
+	_, err := ovs.Transact("db")
+	assert.Nil(t, err)
+	result := <-ovs.TransactAsync("db", NewAbortOperation())
+	assert.NotNil(t, result.Err)
+
+	_, err = ovs.Monitor("db", "m1", nil)
+	assert.Nil(t, err)
+	assert.Nil(t, srv.Notify("update", []interface{}{"m1", testUpdate("table", aUUID0)}))
+	assert.Eventually(t, func() bool {
+		metrics.mutex.Lock()
+		defer metrics.mutex.Unlock()
+		return metrics.updates["table"] == 1
+	}, time.Second, 10*time.Millisecond)
+
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	if assert.Len(t, metrics.transactions, 2) {
+		assert.Nil(t, metrics.transactions[0])
+		assert.NotNil(t, metrics.transactions[1])
+	}
+	// Each of the transact and monitor requests is sent then completed
+	assert.Equal(t, []int{1, 0, 1, 0, 1, 0}, metrics.pending)
+}
+
+func TestDisconnectDoesNotLeakGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		ovs, srv := newTestClient(t, nil)
+		notifier := newTestNotifier()
+		ovs.Register(notifier)
+		if i%2 == 0 {
+			// The server goes away
+			srv.Close()
+		} else {
+			// The client closes the connection while the server does too
+			go srv.Close()
+			ovs.Close()
+		}
+		select {
+		case event := <-notifier.events:
+			assert.Equal(t, "disconnected []", event)
+		case <-time.After(time.Second):
+			t.Fatal("Disconnected not notified")
+		}
+		ovs.Close()
+		select {
+		case event := <-notifier.events:
+			t.Fatalf("unexpected %s notification", event)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, runtime.NumGoroutine() <= before, "%d goroutines left running, %d before", runtime.NumGoroutine(), before)
+}
+
+func TestEchoRequestIsAnswered(t *testing.T) {
+	ovs, srv := newTestClient(t, nil)
+	defer ovs.Close()
+	notifier := newTestNotifier()
+	ovs.Register(notifier)
+
+	var reply []interface{}
+	err := srv.Call("echo", []interface{}{"ping", "pong"}, &reply)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"ping", "pong"}, reply)
+	select {
+	case event := <-notifier.events:
+		assert.Equal(t, "echo [ping pong]", event)
+	case <-time.After(time.Second):
+		t.Fatal("Echo not notified")
+	}
+}
+
+func TestSchemaRefreshIsSafe(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal(testSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	ovs, _ := newTestClient(t, map[string]interface{}{
+		"get_schema": func(_ *rpc2.Client, _ []interface{}, reply *DatabaseSchema) error {
+			*reply = schema
+			return nil
+		},
+		"transact": func(_ *rpc2.Client, _ []interface{}, reply *[]interface{}) error {
+			*reply = []interface{}{}
+			return nil
+		},
+	})
+	defer ovs.Close()
+	_, err := ovs.GetSchema("TestSchema")
+	assert.Nil(t, err)
+	api, ok := ovs.APIFor("TestSchema")
+	assert.True(t, ok)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := ovs.GetSchema("TestSchema")
+			assert.Nil(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := ovs.Transact("TestSchema", Operation{Op: OperationSelect, Table: "TestTable"})
+			assert.Nil(t, err)
+			_, err = api.NewCondition("TestTable", "aString", FunctionEqual, "foo")
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+
+	s, ok := ovs.SchemaFor("TestSchema")
+	assert.True(t, ok)
+	assert.Equal(t, "TestSchema", s.Name)
+	_, ok = ovs.SchemaFor("Unknown")
+	assert.False(t, ok)
+}
+
+func TestClientHasTableColumns(t *testing.T) {
+	ovs, _ := newTestClient(t, nil)
+	defer ovs.Close()
+	assert.False(t, ovs.HasTable("TestSchema", "TestTable"))
+	_, err := ovs.Columns("TestSchema", "TestTable")
+	assert.NotNil(t, err)
+
+	setTestSchema(t, ovs)
+	assert.True(t, ovs.HasTable("TestSchema", "TestTable"))
+	assert.False(t, ovs.HasTable("TestSchema", "Unknown"))
+	assert.False(t, ovs.HasTable("Unknown", "TestTable"))
+	columns, err := ovs.Columns("TestSchema", "TestTable")
+	assert.Nil(t, err)
+	assert.Contains(t, columns, "aString")
+	assert.True(t, sort.StringsAreSorted(columns))
+	_, err = ovs.Columns("TestSchema", "Unknown")
+	assert.NotNil(t, err)
+}
+
+func TestInactivityProbe(t *testing.T) {
+	echoes := make(chan bool, 10)
+	ovs, _ := newTestClient(t, map[string]interface{}{
+		"echo": func(_ *rpc2.Client, args []interface{}, reply *[]interface{}) error {
+			echoes <- true
+			*reply = args
+			return nil
+		},
+	})
+	defer ovs.Close()
+	ovs.SetInactivityProbe(20 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-echoes:
+		case <-time.After(time.Second):
+			t.Fatal("no echo request received")
+		}
+	}
+	assert.True(t, ovs.Connected())
+
+	// Disabling the probe stops the echo requests
+	ovs.SetInactivityProbe(0)
+	time.Sleep(50 * time.Millisecond)
+	for len(echoes) > 0 {
+		<-echoes
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, echoes, 0)
+}
+
+func TestInactivityProbeTimeout(t *testing.T) {
+	release := make(chan bool)
+	defer close(release)
+	ovs, _ := newTestClient(t, map[string]interface{}{
+		// echo requests are never answered in time
+		"echo": func(_ *rpc2.Client, args []interface{}, reply *[]interface{}) error {
+			<-release
+			return nil
+		},
+	})
+	disconnected := make(chan bool, 1)
+	ovs.OnDisconnect(func(*OvsdbClient) {
+		disconnected <- true
+	})
+	ovs.SetInactivityProbe(20 * time.Millisecond)
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("unresponsive connection was not closed")
+	}
+	assert.False(t, ovs.Connected())
+}
+
+func TestInactivityProbeServerNotReading(t *testing.T) {
+	release := make(chan bool)
+	defer close(release)
+	ovs, _ := newBlockingTestClient(t, map[string]interface{}{
+		// transact does not reply, and the server reads nothing, until released
+		"transact": func(_ *rpc2.Client, _ []interface{}, reply *[]interface{}) error {
+			<-release
+			return nil
+		},
+	})
+	ovs.setSchema("db", DatabaseSchema{Name: "db", Tables: map[string]TableSchema{}})
+	disconnected := make(chan bool, 1)
+	ovs.OnDisconnect(func(*OvsdbClient) {
+		disconnected <- true
+	})
+	ovs.TransactAsync("db")
+	// Sending the echo request blocks, which must not block the probe
+	ovs.SetInactivityProbe(20 * time.Millisecond)
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("connection to a server that does not read was not closed")
+	}
+	assert.False(t, ovs.Connected())
+}
+
+// testNotifier is a NotificationHandler that forwards updates through a channel
+type testNotifier struct {
+	updates chan TableUpdates
+	events  chan string
+}
+
+func newTestNotifier() *testNotifier {
+	return &testNotifier{updates: make(chan TableUpdates, 10), events: make(chan string, 10)}
+}
+
+func (n *testNotifier) Update(context interface{}, tableUpdates TableUpdates) {
+	n.updates <- tableUpdates
+}
+func (n *testNotifier) Locked(args []interface{}) {
+	n.event("locked", args)
+}
+func (n *testNotifier) Stolen(args []interface{}) {
+	n.event("stolen", args)
+}
+func (n *testNotifier) event(name string, args []interface{}) {
+	select {
+	case n.events <- fmt.Sprintf("%s %v", name, args):
+	default:
+	}
+}
+func (n *testNotifier) Echo(args []interface{}) {
+	n.event("echo", args)
+}
+func (n *testNotifier) Disconnected(*OvsdbClient) {
+	n.event("disconnected", nil)
+}
+
+// emptyMonitorHandlers make the test server accept any monitor request
+var emptyMonitorHandlers = map[string]interface{}{
+	"monitor": func(_ *rpc2.Client, _ []interface{}, reply *map[string]interface{}) error {
+		*reply = map[string]interface{}{}
+		return nil
+	},
+	"monitor_cancel": func(_ *rpc2.Client, _ []interface{}, reply *map[string]interface{}) error {
+		*reply = map[string]interface{}{}
+		return nil
+	},
+}
+
+func testUpdate(table, uuid string) map[string]interface{} {
+	return map[string]interface{}{
+		table: map[string]interface{}{
+			uuid: map[string]interface{}{
+				"new": map[string]interface{}{"name": "foo"},
+			},
+		},
+	}
+}
+
+func TestMonitorCancelStopsUpdates(t *testing.T) {
+	ovs, srv := newTestClient(t, emptyMonitorHandlers)
+	defer ovs.Close()
+	notifier := newTestNotifier()
+	ovs.Register(notifier)
+
+	_, err := ovs.Monitor("db", "m1", nil)
+	assert.Nil(t, err)
+	// The same handle cannot be used twice
+	_, err = ovs.Monitor("db", "m1", nil)
+	assert.NotNil(t, err)
+
+	assert.Nil(t, srv.Notify("update", []interface{}{"m1", testUpdate("table", aUUID0)}))
+	select {
+	case u := <-notifier.updates:
+		assert.Contains(t, u.Updates["table"].Rows, aUUID0)
+	case <-time.After(time.Second):
+		t.Fatal("update not delivered")
+	}
+
+	assert.Nil(t, ovs.MonitorCancel("m1"))
+	assert.NotNil(t, ovs.MonitorCancel("m1"))
+
+	assert.Nil(t, srv.Notify("update", []interface{}{"m1", testUpdate("table", aUUID1)}))
+	select {
+	case <-notifier.updates:
+		t.Fatal("update delivered for a cancelled monitor")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestUpdatesChannel(t *testing.T) {
+	ovs, srv := newTestClient(t, emptyMonitorHandlers)
+	defer ovs.Close()
+	updates, cancel := ovs.UpdatesChannel(1)
+	// The handlers are notified in the order they were registered, so once
+	// this one has an update, the channel got it too
+	n := newTestNotifier()
+	ovs.Register(n)
+	notify := func(uuid string) {
+		assert.Nil(t, srv.Notify("update", []interface{}{"m1", testUpdate("table", uuid)}))
+		select {
+		case u := <-n.updates:
+			assert.Contains(t, u.Updates["table"].Rows, uuid)
+		case <-time.After(time.Second):
+			t.Fatal("update not delivered")
+		}
+	}
+
+	_, err := ovs.Monitor("db", "m1", nil)
+	assert.Nil(t, err)
+
+	notify(aUUID0)
+	assert.Len(t, updates, 1)
+	// The buffer is full: the update is dropped without blocking the other
+	// handlers, and the channel only holds the first one
+	notify(aUUID1)
+	assert.Len(t, updates, 1)
+	u := <-updates
+	assert.Contains(t, u.Updates["table"].Rows, aUUID0)
+	assert.NotContains(t, u.Updates["table"].Rows, aUUID1)
+	assert.Len(t, updates, 0)
+
+	// Once there is room again, updates are delivered
+	notify(aUUID1)
+	u = <-updates
+	assert.Contains(t, u.Updates["table"].Rows, aUUID1)
+	ovs.Unregister(n)
+
+	cancel()
+	cancel()
+	_, ok := <-updates
+	assert.False(t, ok)
+	assert.Len(t, ovs.handlers, 0)
+}
+
+func TestUpdateMonitor(t *testing.T) {
+	requests := make(chan map[string]interface{}, 3)
+	cancels := make(chan interface{}, 2)
+	ovs, srv := newTestClient(t, map[string]interface{}{
+		"monitor": func(_ *rpc2.Client, args []interface{}, reply *map[string]interface{}) error {
+			r := args[2].(map[string]interface{})
+			requests <- r
+			if _, ok := r["bad"]; ok {
+				return fmt.Errorf("unknown table")
+			}
+			*reply = map[string]interface{}{}
+			return nil
+		},
+		"monitor_cancel": func(_ *rpc2.Client, args []interface{}, reply *map[string]interface{}) error {
+			cancels <- args[0]
+			*reply = map[string]interface{}{}
+			return nil
+		},
+	})
+	defer ovs.Close()
+	n := newTestNotifier()
+
+	_, err := ovs.UpdateMonitor("m1", nil)
+	assert.NotNil(t, err)
+
+	_, err = ovs.MonitorWithHandler("db", "m1", map[string]MonitorRequest{"t1": {}}, n)
+	assert.Nil(t, err)
+	assert.Contains(t, <-requests, "t1")
+
+	_, err = ovs.UpdateMonitor("m1", map[string]MonitorRequest{"t1": {}, "t2": {}})
+	assert.Nil(t, err)
+	assert.Equal(t, "m1", <-cancels)
+	r := <-requests
+	assert.Contains(t, r, "t1")
+	assert.Contains(t, r, "t2")
+
+	// The updates are still delivered to the handler of the monitor
+	assert.Nil(t, srv.Notify("update", []interface{}{"m1", testUpdate("t2", aUUID0)}))
+	select {
+	case u := <-n.updates:
+		assert.Contains(t, u.Updates["t2"].Rows, aUUID0)
+	case <-time.After(time.Second):
+		t.Fatal("update not delivered")
+	}
+
+	// Rejected requests restore the previous ones
+	_, err = ovs.UpdateMonitor("m1", map[string]MonitorRequest{"bad": {}})
+	assert.NotNil(t, err)
+	assert.Equal(t, "m1", <-cancels)
+	assert.Contains(t, <-requests, "bad")
+	r = <-requests
+	assert.Contains(t, r, "t1")
+	assert.Contains(t, r, "t2")
+	_, ok := ovs.getMonitor("m1")
+	assert.True(t, ok)
+}
+
+func TestMultipleMonitors(t *testing.T) {
+	ovs, srv := newTestClient(t, emptyMonitorHandlers)
+	defer ovs.Close()
+	registered := newTestNotifier()
+	ovs.Register(registered)
+	n1 := newTestNotifier()
+	n2 := newTestNotifier()
+
+	_, err := ovs.MonitorWithHandler("db", "m1", nil, n1)
+	assert.Nil(t, err)
+	_, err = ovs.MonitorWithHandler("db", []interface{}{"m", 2}, nil, n2)
+	assert.Nil(t, err)
+	_, err = ovs.Monitor("db", "m3", nil)
+	assert.Nil(t, err)
+
+	expectUpdate := func(n *testNotifier, uuid string) {
+		select {
+		case u := <-n.updates:
+			assert.Contains(t, u.Updates["table"].Rows, uuid)
+		case <-time.After(time.Second):
+			t.Fatal("update not delivered")
+		}
+	}
+
+	assert.Nil(t, srv.Notify("update", []interface{}{"m1", testUpdate("table", aUUID0)}))
+	expectUpdate(n1, aUUID0)
+	assert.Nil(t, srv.Notify("update", []interface{}{[]interface{}{"m", 2}, testUpdate("table", aUUID1)}))
+	expectUpdate(n2, aUUID1)
+	assert.Nil(t, srv.Notify("update", []interface{}{"m3", testUpdate("table", aUUID2)}))
+	expectUpdate(registered, aUUID2)
+
+	assert.Len(t, n1.updates, 0)
+	assert.Len(t, n2.updates, 0)
+	assert.Len(t, registered.updates, 0)
+}
+
+func TestUpdatesOfUnknownMonitors(t *testing.T) {
+	ovs, srv := newTestClient(t, emptyMonitorHandlers)
+	defer ovs.Close()
+	registered := newTestNotifier()
+	ovs.Register(registered)
+	n1 := newTestNotifier()
+
+	expectUpdate := func(n *testNotifier, uuid string) {
+		select {
+		case u := <-n.updates:
+			assert.Contains(t, u.Updates["table"].Rows, uuid)
+		case <-time.After(time.Second):
+			t.Fatal("update not delivered")
+		}
+	}
+
+	// A struct json-value matches the object the server sends back, whose
+	// members are in a different order
+	type handle struct {
+		Name string
+		ID   int
+	}
+	_, err := ovs.MonitorWithHandler("db", handle{Name: "m", ID: 1}, nil, n1)
+	assert.Nil(t, err)
+	assert.Nil(t, srv.Notify("update", []interface{}{map[string]interface{}{"ID": 1, "Name": "m"}, testUpdate("table", aUUID0)}))
+	expectUpdate(n1, aUUID0)
+
+	// The updates of a monitor issued with Call go to the registered handlers
+	var reply json.RawMessage
+	assert.Nil(t, ovs.Call("monitor", NewMonitorArgs("db", "m2", nil), &reply))
+	assert.Nil(t, srv.Notify("update", []interface{}{"m2", testUpdate("table", aUUID1)}))
+	expectUpdate(registered, aUUID1)
+
+	assert.Len(t, n1.updates, 0)
+	assert.Len(t, registered.updates, 0)
+}
+
+func TestUpdateLargeIntegers(t *testing.T) {
+	ovs, srv := newTestClient(t, emptyMonitorHandlers)
+	defer ovs.Close()
+	notifier := newTestNotifier()
+	ovs.Register(notifier)
+
+	_, err := ovs.Monitor("db", "m1", nil)
+	assert.Nil(t, err)
+	// 2^53 + 1 can't be represented by a float64
+	assert.Nil(t, srv.Notify("update", []interface{}{"m1", map[string]interface{}{
+		"table": map[string]interface{}{
+			aUUID0: map[string]interface{}{
+				"new": map[string]interface{}{"count": int64(1<<53 + 1)},
+			},
+		},
+	}}))
+	select {
+	case u := <-notifier.updates:
+		assert.Equal(t, json.Number("9007199254740993"), u.Updates["table"].Rows[aUUID0].New.Fields["count"])
+	case <-time.After(time.Second):
+		t.Fatal("update not delivered")
+	}
+}
+
+// setTestSchema loads the test schema in the client as the "TestSchema" database
+func setTestSchema(t *testing.T, ovs *OvsdbClient) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal(testSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	ovs.setSchema(schema.Name, schema)
+}
+
+func TestMonitorColumns(t *testing.T) {
+	requests := make(chan map[string]interface{}, 1)
+	ovs, _ := newTestClient(t, map[string]interface{}{
+		"monitor": func(_ *rpc2.Client, args []interface{}, reply *map[string]interface{}) error {
+			requests <- args[2].(map[string]interface{})
+			*reply = map[string]interface{}{}
+			return nil
+		},
+	})
+	defer ovs.Close()
+	setTestSchema(t, ovs)
+
+	_, err := ovs.MonitorColumns("TestSchema", "m1", map[string][]string{
+		"TestTable": {"aString", "aMap"},
+	})
+	assert.Nil(t, err)
+	req := <-requests
+	assert.Len(t, req, 1)
+	assert.Equal(t, []interface{}{"aString", "aMap"}, req["TestTable"].(map[string]interface{})["columns"])
+
+	_, err = ovs.MonitorColumns("TestSchema", "m2", map[string][]string{
+		"TestTable": {"notAColumn"},
+	})
+	assert.NotNil(t, err)
+	_, err = ovs.MonitorColumns("TestSchema", "m3", map[string][]string{
+		"NotATable": {"aString"},
+	})
+	assert.NotNil(t, err)
+	assert.Len(t, requests, 0)
+}
+
+func TestMonitorTables(t *testing.T) {
+	requests := make(chan map[string]interface{}, 1)
+	ovs, _ := newTestClient(t, map[string]interface{}{
+		"monitor": func(_ *rpc2.Client, args []interface{}, reply *map[string]interface{}) error {
+			requests <- args[2].(map[string]interface{})
+			*reply = map[string]interface{}{}
+			return nil
+		},
+	})
+	defer ovs.Close()
+	setTestSchema(t, ovs)
+
+	_, err := ovs.MonitorTables("TestSchema", "m1", "TestTable")
+	assert.Nil(t, err)
+	req := <-requests
+	assert.Len(t, req, 1)
+	columns := req["TestTable"].(map[string]interface{})["columns"]
+	tableColumns, err := ovs.Columns("TestSchema", "TestTable")
+	assert.Nil(t, err)
+	assert.Len(t, columns, len(tableColumns))
+	assert.Contains(t, columns, "aString")
+
+	_, err = ovs.MonitorTables("TestSchema", "m2", "TestTable", "NotATable")
+	assert.NotNil(t, err)
+	_, err = ovs.MonitorTables("NotADatabase", "m3", "TestTable")
+	assert.NotNil(t, err)
+	assert.Len(t, requests, 0)
+}
+
+func TestMonitorAllContext(t *testing.T) {
+	release := make(chan bool, 1)
+	cancels := make(chan interface{}, 1)
+	ovs, _ := newBlockingTestClient(t, map[string]interface{}{
+		// monitor does not reply, and the server reads nothing, until released
+		"monitor": func(_ *rpc2.Client, _ []interface{}, reply *map[string]interface{}) error {
+			<-release
+			*reply = map[string]interface{}{}
+			return nil
+		},
+		"monitor_cancel": func(_ *rpc2.Client, args []interface{}, reply *map[string]interface{}) error {
+			cancels <- args[0]
+			*reply = map[string]interface{}{}
+			return nil
+		},
+	})
+	defer ovs.Close()
+	setTestSchema(t, ovs)
+
+	expectCancel := func(jsonContext string) {
+		select {
+		case c := <-cancels:
+			assert.Equal(t, jsonContext, c)
+		case <-time.After(time.Second):
+			t.Fatalf("monitor %s not cancelled", jsonContext)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := ovs.MonitorAllContext(ctx, "TestSchema", "m1")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, time.Since(start) < time.Second)
+	// The monitor is forgotten and cannot be issued again until it is
+	// cancelled on the server
+	_, ok := ovs.getMonitor("m1")
+	assert.False(t, ok)
+	_, err = ovs.MonitorAll("TestSchema", "m1")
+	assert.NotNil(t, err)
+	release <- true
+	expectCancel("m1")
+
+	// A done context does not send anything
+	_, err = ovs.MonitorAllContext(ctx, "TestSchema", "m2")
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// The monitor is cancelled as well when the request times out, and can
+	// then be issued again
+	ovs.SetRequestTimeout(50 * time.Millisecond)
+	_, err = ovs.MonitorAll("TestSchema", "m3")
+	assert.Equal(t, ErrTimeout, err)
+	release <- true
+	expectCancel("m3")
+	assert.Eventually(t, func() bool {
+		release <- true
+		_, err := ovs.MonitorAll("TestSchema", "m3")
+		if err != nil {
+			<-release
+		}
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestConnectWithConn(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	srv := rpc2.NewClientWithCodec(jsonrpc.NewJSONCodec(serverConn))
+	srv.Handle("get_schema", func(_ *rpc2.Client, args []interface{}, reply *json.RawMessage) error {
+		if args[0] != "TestSchema" {
+			return fmt.Errorf("unknown database %v", args[0])
+		}
+		*reply = testSchema
+		return nil
+	})
+	srv.Handle("monitor", emptyMonitorHandlers["monitor"])
+	go srv.Run()
+
+	notifier := newTestNotifier()
+	ovs, err := ConnectWithConn(clientConn, "TestSchema", notifier)
+	assert.Nil(t, err)
+	defer ovs.Close()
+	_, ok := ovs.SchemaFor("TestSchema")
+	assert.True(t, ok)
+	_, ok = ovs.APIFor("TestSchema")
+	assert.True(t, ok)
+	assert.Len(t, ovs.schemas, 1)
+
+	_, err = ovs.MonitorAll("TestSchema", "m1")
+	assert.Nil(t, err)
+	assert.Nil(t, srv.Notify("update", []interface{}{"m1", testUpdate("TestTable", aUUID0)}))
+	select {
+	case u := <-notifier.updates:
+		assert.Contains(t, u.Updates["TestTable"].Rows, aUUID0)
+	case <-time.After(time.Second):
+		t.Fatal("update not delivered to the notifier")
+	}
+}
+
+func TestConnectWithConnUnknownDatabase(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	srv := rpc2.NewClientWithCodec(jsonrpc.NewJSONCodec(serverConn))
+	srv.Handle("get_schema", func(_ *rpc2.Client, args []interface{}, reply *json.RawMessage) error {
+		return fmt.Errorf("unknown database %v", args[0])
+	})
+	go srv.Run()
+
+	_, err := ConnectWithConn(clientConn, "Unknown", nil)
+	assert.NotNil(t, err)
+}
+
+func TestParseEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		network  string
+		address  string
+		err      bool
+	}{
+		{"tcp:", TCP, "127.0.0.1:6640", false},
+		{"tcp:192.168.0.1:6641", TCP, "192.168.0.1:6641", false},
+		{"tcp:192.168.0.1", TCP, "192.168.0.1:6640", false},
+		{"tcp:192.168.0.1:", TCP, "192.168.0.1:6640", false},
+		{"tcp:ovsdb.example.com", TCP, "ovsdb.example.com:6640", false},
+		{"tcp:[2001:db8::1]:6641", TCP, "[2001:db8::1]:6641", false},
+		{"tcp:[2001:db8::1]", TCP, "[2001:db8::1]:6640", false},
+		{"ssl:[::1]", SSL, "[::1]:6640", false},
+		{"ssl:10.0.0.1:6643", SSL, "10.0.0.1:6643", false},
+		{"unix:", UNIX, "/var/run/openvswitch/ovnnb_db.sock", false},
+		{"unix:/var/run/openvswitch/db.sock", UNIX, "/var/run/openvswitch/db.sock", false},
+		{"tcp:2001:db8::1", "", "", true},
+		{"tcp::6640", "", "", true},
+		{"udp:127.0.0.1:6640", "", "", true},
+		{"127.0.0.1", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			network, address, err := parseEndpoint(tt.endpoint)
+			if tt.err {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.network, network)
+			assert.Equal(t, tt.address, address)
+		})
+	}
+}
+
+// serveTestEndpoint serves a fake server that does not hold any database on a
+// local TCP endpoint, which is returned
+func serveTestEndpoint(t *testing.T) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			srv := rpc2.NewClientWithCodec(jsonrpc.NewJSONCodec(conn))
+			srv.Handle("list_dbs", func(_ *rpc2.Client, _ []interface{}, reply *[]string) error {
+				*reply = []string{}
+				return nil
+			})
+			go srv.Run()
+		}
+	}()
+	return "tcp:" + l.Addr().String(), func() { l.Close() }
+}
+
+// unusedEndpoint returns a local TCP endpoint nobody listens on
+func unusedEndpoint(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	return "tcp:" + l.Addr().String()
+}
+
+func TestConnectFailover(t *testing.T) {
+	endpoint, stop := serveTestEndpoint(t)
+	defer stop()
+	down1 := unusedEndpoint(t)
+	down2 := unusedEndpoint(t)
+
+	ovs, err := Connect(strings.Join([]string{down1, down2, endpoint}, ","), nil)
+	assert.Nil(t, err)
+	defer ovs.Close()
+	assert.Equal(t, endpoint, ovs.Endpoint())
+
+	_, err = Connect(strings.Join([]string{down1, down2}, ","), nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), down1)
+	assert.Contains(t, err.Error(), down2)
+}
+
+// pipeDialer connects every dial to an in-process server through a pipe and
+// records the dialed addresses
+type pipeDialer struct {
+	dialed []string
+}
+
+func (d *pipeDialer) DialContext(_ context.Context, network, address string) (net.Conn, error) {
+	d.dialed = append(d.dialed, network+":"+address)
+	clientConn, serverConn := net.Pipe()
+	srv := rpc2.NewClientWithCodec(jsonrpc.NewJSONCodec(serverConn))
+	srv.Handle("list_dbs", func(_ *rpc2.Client, _ []interface{}, reply *[]string) error {
+		*reply = []string{}
+		return nil
+	})
+	go srv.Run()
+	return clientConn, nil
+}
+
+func TestConnectWithDialer(t *testing.T) {
+	dialer := &pipeDialer{}
+	ovs, err := ConnectWithDialer("tcp:ovsdb.example.com:6641", nil, dialer)
+	assert.Nil(t, err)
+	defer ovs.Close()
+	assert.Equal(t, []string{"tcp:ovsdb.example.com:6641"}, dialer.dialed)
+	assert.Equal(t, "tcp:ovsdb.example.com:6641", ovs.Endpoint())
+	assert.True(t, ovs.Connected())
+}
+
+func TestConcurrentTransact(t *testing.T) {
+	ovs, _ := newTestClient(t, map[string]interface{}{
+		// Reply with the uuid-name of the first operation so that every caller
+		// can check it got the reply to its own request
+		"transact": func(_ *rpc2.Client, args []interface{}, reply *[]OperationResult) error {
+			op := args[1].(map[string]interface{})
+			*reply = []OperationResult{{Details: op["uuid-name"].(string)}}
+			return nil
+		},
+	})
+	defer ovs.Close()
+	setTestSchema(t, ovs)
+
+	const n = 500
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			name := fmt.Sprintf("row%d", i)
+			results, err := ovs.Transact("TestSchema", Operation{Op: "insert", Table: "TestTable", UUIDName: name})
+			if err == nil && (len(results) != 1 || results[0].Details != name) {
+				err = fmt.Errorf("%s got reply %v", name, results)
+			}
+			errs <- err
+		}(i)
+	}
+	for i := 0; i < n; i++ {
+		assert.Nil(t, <-errs)
+	}
+}
+
+type panickingNotifier struct {
+	testNotifier
+}
+
+func (n *panickingNotifier) Update(context interface{}, tableUpdates TableUpdates) {
+	panic("buggy handler")
+}
+
+func TestHandlerPanicIsRecovered(t *testing.T) {
+	ovs, srv := newTestClient(t, emptyMonitorHandlers)
+	defer ovs.Close()
+	ovs.Register(&panickingNotifier{})
+	notifier := newTestNotifier()
+	ovs.Register(notifier)
+
+	_, err := ovs.Monitor("db", "m1", nil)
+	assert.Nil(t, err)
+
+	for _, uuid := range []string{aUUID0, aUUID1} {
+		assert.Nil(t, srv.Notify("update", []interface{}{"m1", testUpdate("table", uuid)}))
+		select {
+		case u := <-notifier.updates:
+			assert.Contains(t, u.Updates["table"].Rows, uuid)
+		case <-time.After(time.Second):
+			t.Fatal("update not delivered")
+		}
+	}
+	assert.True(t, ovs.Connected())
+}
+
+func TestTransactWithRetry(t *testing.T) {
+	var attempts int32
+	failures := int32(2)
+	ovs, _ := newTestClient(t, map[string]interface{}{
+		"transact": func(_ *rpc2.Client, _ []interface{}, reply *[]OperationResult) error {
+			if atomic.AddInt32(&attempts, 1) <= atomic.LoadInt32(&failures) {
+				*reply = []OperationResult{{Error: "not leader"}}
+				return nil
+			}
+			*reply = []OperationResult{{Count: 1}}
+			return nil
+		},
+	})
+	defer ovs.Close()
+	setTestSchema(t, ovs)
+	op := Operation{Op: OperationDelete, Table: "TestTable"}
+	policy := RetryPolicy{MaxAttempts: 3, Interval: time.Millisecond, RetryableErrors: []string{"not leader"}}
+
+	results, err := ovs.TransactWithRetry(context.Background(), "TestSchema", policy, op)
+	assert.Nil(t, err)
+	assert.Equal(t, []OperationResult{{Count: 1}}, results)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+
+	// Attempts are exhausted: the last result is returned
+	atomic.StoreInt32(&attempts, 0)
+	atomic.StoreInt32(&failures, 5)
+	results, err = ovs.TransactWithRetry(context.Background(), "TestSchema", policy, op)
+	assert.Equal(t, ErrNotLeader, err)
+	assert.Equal(t, "not leader", results[0].Error)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+
+	// Errors that are not retryable are returned straight away
+	atomic.StoreInt32(&attempts, 0)
+	policy.RetryableErrors = []string{"timed out"}
+	results, err = ovs.TransactWithRetry(context.Background(), "TestSchema", policy, op)
+	assert.Equal(t, ErrNotLeader, err)
+	assert.Equal(t, "not leader", results[0].Error)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+
+	// The context stops the retries
+	atomic.StoreInt32(&attempts, 0)
+	policy = RetryPolicy{MaxAttempts: 5, Interval: time.Hour, RetryableErrors: []string{"not leader"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = ovs.TransactWithRetry(ctx, "TestSchema", policy, op)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestTransactNotLeader(t *testing.T) {
+	var rejectRequest int32
+	ovs, _ := newTestClient(t, map[string]interface{}{
+		"transact": func(_ *rpc2.Client, _ []interface{}, reply *[]OperationResult) error {
+			if atomic.LoadInt32(&rejectRequest) == 1 {
+				return fmt.Errorf("not leader")
+			}
+			*reply = []OperationResult{{Error: "not leader", Details: "ovsdb-server is not the cluster leader"}}
+			return nil
+		},
+	})
+	defer ovs.Close()
+	setTestSchema(t, ovs)
+	op := Operation{Op: OperationDelete, Table: "TestTable"}
+
+	// The transaction failed: its results are returned as well
+	results, err := ovs.Transact("TestSchema", op)
+	assert.Equal(t, ErrNotLeader, err)
+	assert.Len(t, results, 1)
+	result := <-ovs.TransactAsync("TestSchema", op)
+	assert.Equal(t, ErrNotLeader, result.Err)
+	assert.Len(t, result.Results, 1)
+
+	// The request as a whole was rejected
+	atomic.StoreInt32(&rejectRequest, 1)
+	results, err = ovs.Transact("TestSchema", op)
+	assert.Equal(t, ErrNotLeader, err)
+	assert.Nil(t, results)
+}
+
+func TestTransactAsync(t *testing.T) {
+	ovs, _ := newTestClient(t, map[string]interface{}{
+		"transact": func(_ *rpc2.Client, args []interface{}, reply *[]OperationResult) error {
+			op := args[1].(map[string]interface{})
+			*reply = []OperationResult{{Details: op["uuid-name"].(string)}}
+			return nil
+		},
+	})
+	defer ovs.Close()
+	setTestSchema(t, ovs)
+
+	const n = 100
+	var pending []<-chan TransactResult
+	for i := 0; i < n; i++ {
+		op := Operation{Op: OperationInsert, Table: "TestTable", UUIDName: fmt.Sprintf("row%d", i)}
+		pending = append(pending, ovs.TransactAsync("TestSchema", op))
+	}
+	for i, result := range pending {
+		r := <-result
+		assert.Nil(t, r.Err)
+		assert.Equal(t, []OperationResult{{Details: fmt.Sprintf("row%d", i)}}, r.Results)
+	}
+
+	r := <-ovs.TransactAsync("NoSuchDB", Operation{Op: OperationInsert, Table: "TestTable"})
+	assert.NotNil(t, r.Err)
+}
+
+func TestTransactionComment(t *testing.T) {
+	comments := make(chan string, 10)
+	ovs, _ := newTestClient(t, map[string]interface{}{
+		// Every operation succeeds with its op and comment as details
+		"transact": func(_ *rpc2.Client, args []interface{}, reply *[]OperationResult) error {
+			for _, arg := range args[1:] {
+				op := arg.(map[string]interface{})
+				details := op["op"].(string)
+				if comment, ok := op["comment"]; ok {
+					details += " " + comment.(string)
+					comments <- comment.(string)
+				}
+				*reply = append(*reply, OperationResult{Details: details})
+			}
+			return nil
+		},
+	})
+	defer ovs.Close()
+	setTestSchema(t, ovs)
+	op := Operation{Op: OperationDelete, Table: "TestTable"}
+
+	var requests int32
+	ovs.SetTransactionComment(func() string {
+		return fmt.Sprintf("controller request %d", atomic.AddInt32(&requests, 1))
+	})
+
+	// The comment is sent first and its result is not returned
+	results, err := ovs.Transact("TestSchema", op)
+	assert.Nil(t, err)
+	assert.Equal(t, []OperationResult{{Details: "delete"}}, results)
+	assert.Equal(t, "controller request 1", <-comments)
+	results, err = ovs.Transact("TestSchema", NewCommentOperation("mine"), op)
+	assert.Nil(t, err)
+	assert.Equal(t, []OperationResult{{Details: "comment mine"}, {Details: "delete"}}, results)
+	assert.Equal(t, "controller request 2", <-comments)
+	assert.Equal(t, "mine", <-comments)
+	r := <-ovs.TransactAsync("TestSchema", op)
+	assert.Nil(t, r.Err)
+	assert.Equal(t, []OperationResult{{Details: "delete"}}, r.Results)
+	assert.Equal(t, "controller request 3", <-comments)
+
+	// An empty comment is not sent
+	ovs.SetTransactionComment(func() string { return "" })
+	results, err = ovs.Transact("TestSchema", NewCommentOperation("mine"), op)
+	assert.Nil(t, err)
+	assert.Equal(t, []OperationResult{{Details: "comment mine"}, {Details: "delete"}}, results)
+	assert.Equal(t, "mine", <-comments)
+
+	ovs.SetTransactionComment(nil)
+	results, err = ovs.Transact("TestSchema", op)
+	assert.Nil(t, err)
+	assert.Equal(t, []OperationResult{{Details: "delete"}}, results)
+	assert.Len(t, comments, 0)
+}
+
+func TestNotificationsFanOut(t *testing.T) {
+	ovs, srv := newTestClient(t, emptyMonitorHandlers)
+	defer ovs.Close()
+	n1 := newTestNotifier()
+	n2 := newTestNotifier()
+	ovs.Register(n1)
+	ovs.Register(n2)
+
+	assert.Nil(t, srv.Notify("locked", []interface{}{"lock0"}))
+	assert.Nil(t, srv.Notify("stolen", []interface{}{"lock0"}))
+	for _, n := range []*testNotifier{n1, n2} {
+		for _, expected := range []string{"locked [lock0]", "stolen [lock0]"} {
+			select {
+			case event := <-n.events:
+				assert.Equal(t, expected, event)
+			case <-time.After(time.Second):
+				t.Fatalf("%s not delivered", expected)
+			}
+		}
+	}
+
+	assert.Nil(t, ovs.Unregister(n2))
+	assert.Nil(t, srv.Notify("locked", []interface{}{"lock1"}))
+	select {
+	case event := <-n1.events:
+		assert.Equal(t, "locked [lock1]", event)
+	case <-time.After(time.Second):
+		t.Fatal("locked not delivered")
+	}
+	select {
+	case <-n2.events:
+		t.Fatal("notification delivered to an unregistered handler")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
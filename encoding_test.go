@@ -133,6 +133,25 @@ func TestMap(t *testing.T) {
 	}
 }
 
+func TestMapMarshalIsSorted(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{map[string]string{"c": "3", "a": "1", "b": "2", "aa": "0"}, `["map",[["a","1"],["aa","0"],["b","2"],["c","3"]]]`},
+		{map[int]string{10: "ten", 2: "two", -1: "minus one"}, `["map",[[-1,"minus one"],[2,"two"],[10,"ten"]]]`},
+	}
+	for _, test := range tests {
+		m, err := NewOvsMap(test.input)
+		assert.Nil(t, err)
+		for i := 0; i < 10; i++ {
+			b, err := json.Marshal(m)
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, string(b))
+		}
+	}
+}
+
 func TestSet(t *testing.T) {
 	for _, e := range setTestList {
 		set, err := NewOvsSet(e.objInput)
@@ -144,6 +163,216 @@ func TestSet(t *testing.T) {
 		var res OvsSet
 		err = json.Unmarshal(jsonStr, &res)
 		assert.Nil(t, err)
-		assert.Equal(t, set.GoSet, res.GoSet, "they should have the same elements\n")
+		assert.Len(t, res.GoSet, len(set.GoSet))
+		for i, v := range res.GoSet {
+			// Numbers are decoded as json.Number to keep their precision
+			if n, ok := v.(json.Number); ok {
+				b, err := json.Marshal(set.GoSet[i])
+				assert.Nil(t, err)
+				assert.Equal(t, string(b), n.String())
+				continue
+			}
+			assert.Equal(t, set.GoSet[i], v, "they should have the same elements\n")
+		}
+	}
+}
+
+func TestSetRejectsMixedElements(t *testing.T) {
+	_, err := NewOvsSet([]interface{}{"a", "b"})
+	assert.Nil(t, err)
+	_, err = NewOvsSet([]interface{}{UUID{GoUUID: "a"}, UUID{GoUUID: "b"}})
+	assert.Nil(t, err)
+
+	_, err = NewOvsSet([]interface{}{"a", 1})
+	assert.NotNil(t, err)
+	_, err = NewOvsSet([]interface{}{"a", nil})
+	assert.NotNil(t, err)
+	_, err = NewOvsSet([][]string{{"a"}})
+	assert.NotNil(t, err)
+	_, err = NewOvsSet([]struct{ A string }{{"a"}})
+	assert.NotNil(t, err)
+}
+
+func TestSetDropsDuplicates(t *testing.T) {
+	set, err := NewOvsSet([]string{"a", "b", "a", "c", "b"})
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"a", "b", "c"}, set.GoSet)
+
+	set, err = NewOvsSet([]UUID{validUUID1, validUUID0, validUUID1})
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{validUUID1, validUUID0}, set.GoSet)
+	b, err := json.Marshal(set)
+	assert.Nil(t, err)
+	assert.Equal(t, fmt.Sprintf(`["set",[["uuid","%s"],["uuid","%s"]]]`, validUUIDStr1, validUUIDStr0), string(b))
+
+	// The uuid sets of NativeToOvs are deduplicated the same way
+	var column ColumnSchema
+	err = json.Unmarshal([]byte(`{"type":{"key":"uuid","min":0,"max":"unlimited"}}`), &column)
+	assert.Nil(t, err)
+	ovsElem, err := NativeToOvs(&column, []string{validUUIDStr0, validUUIDStr0})
+	assert.Nil(t, err)
+	assert.Equal(t, &OvsSet{GoSet: []interface{}{validUUID0}}, ovsElem)
+}
+
+func TestSetAddRemoveContains(t *testing.T) {
+	set, err := NewOvsSet([]UUID{validUUID0})
+	assert.Nil(t, err)
+	assert.True(t, set.Contains(validUUID0))
+	assert.False(t, set.Contains(validUUID1))
+
+	err = set.Add(validUUID1)
+	assert.Nil(t, err)
+	assert.True(t, set.Contains(validUUID1))
+	assert.Equal(t, []interface{}{validUUID0, validUUID1}, set.GoSet)
+
+	// Adding an existing element does not duplicate it
+	err = set.Add(validUUID1)
+	assert.Nil(t, err)
+	assert.Len(t, set.GoSet, 2)
+
+	// Adding an element of a different type is rejected
+	err = set.Add(validUUIDStr0)
+	assert.NotNil(t, err)
+	assert.Len(t, set.GoSet, 2)
+
+	set.Remove(validUUID0)
+	assert.False(t, set.Contains(validUUID0))
+	assert.Equal(t, []interface{}{validUUID1}, set.GoSet)
+
+	// Removing a missing element is a no-op
+	set.Remove(validUUID0)
+	assert.Equal(t, []interface{}{validUUID1}, set.GoSet)
+
+	// An empty set accepts any element type
+	empty, err := NewOvsSet([]string{})
+	assert.Nil(t, err)
+	err = empty.Add("aa")
+	assert.Nil(t, err)
+	assert.True(t, empty.Contains("aa"))
+}
+
+func TestMapGetSetDelete(t *testing.T) {
+	m, err := NewOvsMap(map[string]string{"foo": "bar"})
+	assert.Nil(t, err)
+
+	val, ok := m.Get("foo")
+	assert.True(t, ok)
+	assert.Equal(t, "bar", val)
+	_, ok = m.Get("baz")
+	assert.False(t, ok)
+
+	err = m.Set("baz", "qux")
+	assert.Nil(t, err)
+	val, ok = m.Get("baz")
+	assert.True(t, ok)
+	assert.Equal(t, "qux", val)
+
+	// Pairs of a different type are rejected
+	err = m.Set(1, "qux")
+	assert.NotNil(t, err)
+	err = m.Set("baz", 1)
+	assert.NotNil(t, err)
+	assert.Equal(t, map[interface{}]interface{}{"foo": "bar", "baz": "qux"}, m.GoMap)
+
+	m.Delete("foo")
+	_, ok = m.Get("foo")
+	assert.False(t, ok)
+	assert.Len(t, m.GoMap, 1)
+
+	// A zero OvsMap can be populated
+	var empty OvsMap
+	err = empty.Set("foo", 1)
+	assert.Nil(t, err)
+	val, ok = empty.Get("foo")
+	assert.True(t, ok)
+	assert.Equal(t, 1, val)
+}
+
+func TestSetMapEqual(t *testing.T) {
+	set, err := NewOvsSet([]string{"foo", "bar", "baz"})
+	assert.Nil(t, err)
+	reordered, err := NewOvsSet([]string{"baz", "foo", "bar"})
+	assert.Nil(t, err)
+	other, err := NewOvsSet([]string{"foo", "bar", "qux"})
+	assert.Nil(t, err)
+	assert.True(t, set.Equal(reordered))
+	assert.True(t, reordered.Equal(set))
+	assert.False(t, set.Equal(other))
+	assert.False(t, set.Equal(&OvsSet{GoSet: []interface{}{"foo", "bar"}}))
+
+	uuids := OvsSet{GoSet: []interface{}{validUUID0, validUUID1}}
+	assert.True(t, uuids.Equal(&OvsSet{GoSet: []interface{}{validUUID1, validUUID0}}))
+	assert.False(t, uuids.Equal(&OvsSet{GoSet: []interface{}{validUUIDStr1, validUUIDStr0}}))
+
+	// A nil set is equal to an empty one
+	var nilSet *OvsSet
+	assert.True(t, nilSet.Equal(&OvsSet{}))
+	assert.True(t, (&OvsSet{}).Equal(nilSet))
+	assert.False(t, nilSet.Equal(set))
+
+	m, err := NewOvsMap(map[string]string{"foo": "bar", "baz": "qux"})
+	assert.Nil(t, err)
+	same, err := NewOvsMap(map[string]string{"baz": "qux", "foo": "bar"})
+	assert.Nil(t, err)
+	assert.True(t, m.Equal(same))
+	different, err := NewOvsMap(map[string]string{"foo": "bar", "baz": "quux"})
+	assert.Nil(t, err)
+	assert.False(t, m.Equal(different))
+	different, err = NewOvsMap(map[string]string{"foo": "bar", "quux": "qux"})
+	assert.Nil(t, err)
+	assert.False(t, m.Equal(different))
+	assert.False(t, m.Equal(&OvsMap{GoMap: map[interface{}]interface{}{"foo": "bar"}}))
+
+	var nilMap *OvsMap
+	assert.True(t, nilMap.Equal(&OvsMap{}))
+	assert.True(t, (&OvsMap{}).Equal(nilMap))
+	assert.False(t, nilMap.Equal(m))
+}
+
+func TestLenIsEmpty(t *testing.T) {
+	set, err := NewOvsSet([]string{"foo", "bar"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, set.Len())
+	assert.False(t, set.IsEmpty())
+	set.Remove("foo")
+	set.Remove("bar")
+	assert.Equal(t, 0, set.Len())
+	assert.True(t, set.IsEmpty())
+	var nilSet *OvsSet
+	assert.True(t, nilSet.IsEmpty())
+
+	m, err := NewOvsMap(map[string]string{"foo": "bar"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, m.Len())
+	assert.False(t, m.IsEmpty())
+	m.Delete("foo")
+	assert.True(t, m.IsEmpty())
+	var nilMap *OvsMap
+	assert.Equal(t, 0, nilMap.Len())
+	assert.True(t, (&OvsMap{}).IsEmpty())
+}
+
+func TestUUIDUnmarshal(t *testing.T) {
+	for _, b := range []string{`["uuid","` + validUUIDStr0 + `"]`, `["named-uuid","gopher"]`} {
+		var uuid UUID
+		assert.Nil(t, json.Unmarshal([]byte(b), &uuid))
+		out, err := json.Marshal(uuid)
+		assert.Nil(t, err)
+		assert.JSONEq(t, b, string(out))
+	}
+	for _, b := range []string{`["uuid"]`, `["set",[]]`, `["uuid","a","b"]`, `"` + validUUIDStr0 + `"`} {
+		var uuid UUID
+		assert.NotNil(t, json.Unmarshal([]byte(b), &uuid), b)
+	}
+}
+
+func TestUUIDValidate(t *testing.T) {
+	for _, u := range []UUID{validUUID0, {GoUUID: "named"}, {GoUUID: "_row_1"}} {
+		assert.Nil(t, u.Validate(), u.GoUUID)
+	}
+	for _, u := range []UUID{{GoUUID: ""}, {GoUUID: "1row"}, {GoUUID: "row-1"}, {GoUUID: "2F77B348-9768-4866-B761-89D5177ECDA0"}} {
+		assert.NotNil(t, u.Validate(), u.GoUUID)
 	}
+	assert.False(t, validUUID0.IsNamed())
+	assert.True(t, UUID{GoUUID: "named"}.IsNamed())
 }
@@ -28,7 +28,8 @@ func play(ovs *libovsdb.OvsdbClient) {
 			for table, tableUpdate := range currUpdate.Updates {
 				if table == bridgeTable {
 					for uuid, row := range tableUpdate.Rows {
-						rowData, err := ovs.Apis[ovsDb].GetRowData(bridgeTable, &row.New)
+						api, _ := ovs.APIFor(ovsDb)
+						rowData, err := api.GetRowData(bridgeTable, &row.New)
 						if err != nil {
 							fmt.Println("ERROR getting Bridge Data", err)
 						}
@@ -49,7 +50,7 @@ func play(ovs *libovsdb.OvsdbClient) {
 }
 
 func createBridge(ovs *libovsdb.OvsdbClient, bridgeName string) {
-	api := ovs.Apis[ovsDb]
+	api, _ := ovs.APIFor(ovsDb)
 	namedUUID := "gopher"
 	// bridge row to insert
 	bridge := make(map[string]interface{})
@@ -23,6 +23,7 @@ func usage() {
 var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to this file")
 var memprofile = flag.String("memoryprofile", "", "write memory profile to this file")
 var ntimes = flag.Int("ntimes", 1, "Parse the schema N times. Useful for profiling")
+var format = flag.String("format", "text", "Output format: text, json or dot")
 
 var schemas []libovsdb.DatabaseSchema
 
@@ -76,6 +77,15 @@ func main() {
 
 	// It only really makes sense to print 1 time
 	if *ntimes > 0 {
-		schemas[0].Print(os.Stdout)
+		switch *format {
+		case "json":
+			if err := schemas[0].PrintJSON(os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+		case "dot":
+			schemas[0].PrintDot(os.Stdout)
+		default:
+			schemas[0].Print(os.Stdout)
+		}
 	}
 }
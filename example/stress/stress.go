@@ -94,10 +94,16 @@ func transact(ovs *libovsdb.OvsdbClient, operations []libovsdb.Operation) (ok bo
 			ok = false
 		}
 	}
-	uuid = reply[0].UUID.GoUUID
+	uuid, _ = libovsdb.InsertedUUID(reply, 0)
 	return
 }
 
+// ovsAPI returns the NativeAPI of the Open_vSwitch database
+func ovsAPI(ovs *libovsdb.OvsdbClient) libovsdb.NativeAPI {
+	nativeAPI, _ := ovs.APIFor("Open_vSwitch")
+	return nativeAPI
+}
+
 func populateCache(ovs *libovsdb.OvsdbClient, updates libovsdb.TableUpdates) {
 	cache = make(map[string]map[string]interface{})
 	for table, tableUpdate := range updates.Updates {
@@ -108,7 +114,7 @@ func populateCache(ovs *libovsdb.OvsdbClient, updates libovsdb.TableUpdates) {
 			empty := libovsdb.Row{}
 			if !reflect.DeepEqual(row.New, empty) {
 				if *api == "native" {
-					rowData, err := ovs.Apis["Open_vSwitch"].GetRowData(table, &row.New)
+					rowData, err := ovsAPI(ovs).GetRowData(table, &row.New)
 					if err != nil {
 						log.Fatal(err)
 					}
@@ -133,15 +139,15 @@ func deleteBridge(ovs *libovsdb.OvsdbClient, uuid string) {
 	var mutCondition []interface{}
 
 	if *api == "native" {
-		delCondition, err = ovs.Apis["Open_vSwitch"].NewCondition("Bridge", "_uuid", "==", uuid)
+		delCondition, err = ovsAPI(ovs).NewCondition("Bridge", "_uuid", "==", uuid)
 		if err != nil {
 			log.Fatal(err)
 		}
-		mutation, err = ovs.Apis["Open_vSwitch"].NewMutation("Open_vSwitch", "bridges", "delete", []string{uuid})
+		mutation, err = ovsAPI(ovs).NewMutation("Open_vSwitch", "bridges", "delete", []string{uuid})
 		if err != nil {
 			log.Fatal(err)
 		}
-		mutCondition, err = ovs.Apis["Open_vSwitch"].NewMutation("Open_vSwitch", "_uuid", "==", rootUUID)
+		mutCondition, err = ovsAPI(ovs).NewCondition("Open_vSwitch", "_uuid", "==", rootUUID)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -198,7 +204,7 @@ func createBridge(ovs *libovsdb.OvsdbClient, iter int) {
 		bridge["datapath_id"] = datapathID
 		nbridge["external_ids"] = externalIds
 
-		bridge, err = ovs.Apis["Open_vSwitch"].NewRow("Bridge", nbridge)
+		bridge, err = ovsAPI(ovs).NewRow("Bridge", nbridge)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -234,11 +240,11 @@ func createBridge(ovs *libovsdb.OvsdbClient, iter int) {
 	// Inserting a Bridge row in Bridge table requires mutating the open_vswitch table.
 	if *api == "native" {
 		// Inserting a Bridge row in Bridge table requires mutating the open_vswitch table.
-		mutation, err = ovs.Apis["Open_vSwitch"].NewMutation("Open_vSwitch", "bridges", "insert", []string{namedUUID})
+		mutation, err = ovsAPI(ovs).NewMutation("Open_vSwitch", "bridges", "insert", []string{namedUUID})
 		if err != nil {
 			log.Fatalf("Mutation Error: %s", err.Error())
 		}
-		condition, err = ovs.Apis["Open_vSwitch"].NewCondition("Open_vSwitch", "_uuid", "==", rootUUID)
+		condition, err = ovsAPI(ovs).NewCondition("Open_vSwitch", "_uuid", "==", rootUUID)
 		if err != nil {
 			log.Fatalf("Condition Error: %s", err.Error())
 		}
@@ -3,7 +3,9 @@ package libovsdb
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"reflect"
+	"sort"
 )
 
 // OvsMap is the JSON map structure used for OVSDB
@@ -18,14 +20,21 @@ type OvsMap struct {
 }
 
 // MarshalJSON marshalls an OVSDB style Map to a byte array
+// The pairs are sorted by key so that the encoding of a given map is stable
 func (o OvsMap) MarshalJSON() ([]byte, error) {
 	if len(o.GoMap) > 0 {
+		keys := make([]interface{}, 0, len(o.GoMap))
+		for key := range o.GoMap {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return mapKeyLess(keys[i], keys[j]) })
+
 		var ovsMap, innerMap []interface{}
 		ovsMap = append(ovsMap, "map")
-		for key, val := range o.GoMap {
+		for _, key := range keys {
 			var mapSeg []interface{}
 			mapSeg = append(mapSeg, key)
-			mapSeg = append(mapSeg, val)
+			mapSeg = append(mapSeg, o.GoMap[key])
 			innerMap = append(innerMap, mapSeg)
 		}
 		ovsMap = append(ovsMap, innerMap)
@@ -34,11 +43,44 @@ func (o OvsMap) MarshalJSON() ([]byte, error) {
 	return []byte("[\"map\",[]]"), nil
 }
 
+// mapKeyLess orders map keys: strings lexically, numbers
+// numerically and anything else (e.g: UUIDs) by its string representation
+func mapKeyLess(a, b interface{}) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch {
+	case va.Kind() == reflect.String && vb.Kind() == reflect.String:
+		return va.String() < vb.String()
+	case isNumber(va) && isNumber(vb):
+		return toFloat(va) < toFloat(vb)
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+func isNumber(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	}
+	return v.Float()
+}
+
 // UnmarshalJSON unmarshalls an OVSDB style Map from a byte array
 func (o *OvsMap) UnmarshalJSON(b []byte) (err error) {
 	var oMap []interface{}
 	o.GoMap = make(map[interface{}]interface{})
-	if err := json.Unmarshal(b, &oMap); err == nil && len(oMap) > 1 {
+	if err := unmarshalUseNumber(b, &oMap); err == nil && len(oMap) > 1 {
 		innerSlice := oMap[1].([]interface{})
 		for _, val := range innerSlice {
 			f := val.([]interface{})
@@ -62,3 +104,64 @@ func NewOvsMap(goMap interface{}) (*OvsMap, error) {
 	}
 	return &OvsMap{genMap}, nil
 }
+
+// Get returns the value associated with key and whether it was present in the map
+func (o *OvsMap) Get(key interface{}) (interface{}, bool) {
+	val, ok := o.GoMap[key]
+	return val, ok
+}
+
+// Set associates value with key. Since all the pairs of an OVSDB map must have the
+// same key and value types, key and value must match the types of the existing pairs
+func (o *OvsMap) Set(key, value interface{}) error {
+	for k, v := range o.GoMap {
+		if reflect.TypeOf(key) != reflect.TypeOf(k) {
+			return NewErrWrongType("OvsMap.Set", reflect.TypeOf(k).String(), key)
+		}
+		if reflect.TypeOf(value) != reflect.TypeOf(v) {
+			return NewErrWrongType("OvsMap.Set", reflect.TypeOf(v).String(), value)
+		}
+		break
+	}
+	if o.GoMap == nil {
+		o.GoMap = make(map[interface{}]interface{})
+	}
+	o.GoMap[key] = value
+	return nil
+}
+
+// Delete removes key from the map, if present
+func (o *OvsMap) Delete(key interface{}) {
+	delete(o.GoMap, key)
+}
+
+// Len returns the number of pairs of the map. A nil map is empty
+func (o *OvsMap) Len() int {
+	if o == nil {
+		return 0
+	}
+	return len(o.GoMap)
+}
+
+// IsEmpty returns whether the map has no pair
+func (o *OvsMap) IsEmpty() bool {
+	return o.Len() == 0
+}
+
+// Equal returns whether both maps have the same pairs. A nil map is equal to an
+// empty one
+func (o *OvsMap) Equal(other *OvsMap) bool {
+	if o.Len() != other.Len() {
+		return false
+	}
+	if o.IsEmpty() {
+		return true
+	}
+	for k, v := range o.GoMap {
+		otherValue, ok := other.GoMap[k]
+		if !ok || !reflect.DeepEqual(v, otherValue) {
+			return false
+		}
+	}
+	return true
+}
@@ -2,6 +2,8 @@ package libovsdb
 
 import (
 	"fmt"
+	"reflect"
+	"sync/atomic"
 )
 
 // ErrNoTable describes a error in the provided table information
@@ -30,7 +32,7 @@ func NewErrNoTable(table string) error {
 //
 // OvsUUID are translated to and from strings
 // If the column type is an enum, the native type associated with the underlying enum
-// type is used (e.g: string or int)
+// type is used (e.g: string or int64)
 // Also, type checkings are done. E.g: if you try to put an integer in a column that has
 // type string, the API will refuse to create the Ovs object for you
 type NativeAPI struct {
@@ -44,6 +46,44 @@ func NewNativeAPI(schema *DatabaseSchema) NativeAPI {
 	}
 }
 
+// namedUUIDCount numbers the named-uuids generated by BuildInsertWithRef
+var namedUUIDCount uint64
+
+// BuildInsertWithRef returns the operations that insert a row (as in NewRow) in
+// childTable and add a reference to it to the parentColumn set of the row of
+// parentTable whose UUID is parentUUID, along with the named-uuid of the new
+// row, which can be used to reference it in further operations of the same
+// transaction
+func (na NativeAPI) BuildInsertWithRef(childTable string, child interface{}, parentTable, parentColumn, parentUUID string) ([]Operation, string, error) {
+	row, err := na.NewRow(childTable, child)
+	if err != nil {
+		return nil, "", err
+	}
+	namedUUID := fmt.Sprintf("%s_%d", childTable, atomic.AddUint64(&namedUUIDCount, 1))
+	mutation, err := na.NewMutation(parentTable, parentColumn, MutatorInsert, []string{namedUUID})
+	if err != nil {
+		return nil, "", err
+	}
+	condition, err := na.NewCondition(parentTable, "_uuid", FunctionEqual, parentUUID)
+	if err != nil {
+		return nil, "", err
+	}
+	return []Operation{
+		{
+			Op:       OperationInsert,
+			Table:    childTable,
+			Row:      row,
+			UUIDName: namedUUID,
+		},
+		{
+			Op:        OperationMutate,
+			Table:     parentTable,
+			Mutations: []interface{}{mutation},
+			Where:     []interface{}{condition},
+		},
+	}, namedUUID, nil
+}
+
 // GetRowData transforms a Row to a native type data map[string] interface{}
 func (na NativeAPI) GetRowData(tableName string, row *Row) (map[string]interface{}, error) {
 	if row == nil {
@@ -52,9 +92,42 @@ func (na NativeAPI) GetRowData(tableName string, row *Row) (map[string]interface
 	return na.GetData(tableName, row.Fields)
 }
 
+// GetRowDataWithUUID transforms a Row to a native type data map[string] interface{}
+// like GetRowData, and sets its "_uuid" column to uuid. Rows received in
+// TableUpdates do not hold their UUID, which is the key they are indexed by
+func (na NativeAPI) GetRowDataWithUUID(tableName string, uuid string, row *Row) (map[string]interface{}, error) {
+	if row == nil {
+		return nil, nil
+	}
+	data, err := na.GetData(tableName, row.Fields)
+	if err != nil {
+		return nil, err
+	}
+	data["_uuid"] = uuid
+	return data, nil
+}
+
+// GetResultData transforms the rows of an OperationResult (e.g: the result of a
+// select Operation) to native type data maps
+func (na NativeAPI) GetResultData(tableName string, result OperationResult) ([]map[string]interface{}, error) {
+	data := make([]map[string]interface{}, 0, len(result.Rows))
+	for i, row := range result.Rows {
+		nativeRow, err := na.GetData(tableName, row)
+		if err != nil {
+			return nil, fmt.Errorf("Row %d: %s", i, err.Error())
+		}
+		data = append(data, nativeRow)
+	}
+	return data, nil
+}
+
 // GetData transforms a map[string]interface{} containing OvS types (e.g: a ResultRow
 // has this format) to native.
-// The result object must be given as pointer to map[string] interface{}
+// Only the columns present in ovsData are set in the result, so a column that
+// was not selected is missing from it, while an empty one holds an empty value
+// (e.g: "" or an empty slice)
+// Values that are not part of the enum of their column are rejected, since they
+// reveal a mismatch between the schema and the database
 func (na NativeAPI) GetData(tableName string, ovsData map[string]interface{}) (map[string]interface{}, error) {
 	table, ok := na.schema.Tables[tableName]
 	if !ok {
@@ -79,6 +152,9 @@ func (na NativeAPI) GetData(tableName string, ovsData map[string]interface{}) (m
 
 // NewRow creates a libovsdb Row from the input data
 // data shall not contain libovsdb-specific types (except UUID)
+// Set and map columns whose value is a nil slice or map are considered unset and
+// omitted from the row, whereas empty (non-nil) ones are included as an empty
+// OVSDB set or map, clearing the column
 func (na NativeAPI) NewRow(tableName string, data interface{}) (map[string]interface{}, error) {
 	table, ok := na.schema.Tables[tableName]
 	if !ok {
@@ -92,7 +168,7 @@ func (na NativeAPI) NewRow(tableName string, data interface{}) (map[string]inter
 	ovsRow := make(map[string]interface{}, len(table.Columns))
 	for name, column := range table.Columns {
 		nativeElem, ok := nativeRow[name]
-		if !ok {
+		if !ok || isNilCollection(column, nativeElem) {
 			// Ignore missing columns
 			continue
 		}
@@ -105,14 +181,91 @@ func (na NativeAPI) NewRow(tableName string, data interface{}) (map[string]inter
 	return ovsRow, nil
 }
 
+// defaultUUID is the default value of a uuid (RFC7047 section 5.1)
+const defaultUUID = "00000000-0000-0000-0000-000000000000"
+
+// NewClearRow returns an update row that resets the provided columns of a table
+// to their default value: an empty set or map, or the default value of atomic
+// types (0, 0.0, false, "" or the all-zero UUID).
+// Enum columns are refused because their default value may not be valid
+func (na NativeAPI) NewClearRow(tableName string, columns ...string) (map[string]interface{}, error) {
+	ovsRow := make(map[string]interface{}, len(columns))
+	for _, name := range columns {
+		column, err := na.schema.GetColumn(tableName, name)
+		if err != nil {
+			return nil, err
+		}
+		nativeElem, err := defaultNativeValue(column)
+		if err != nil {
+			return nil, fmt.Errorf("Table %s, Column %s: %s", tableName, name, err.Error())
+		}
+		ovsElem, err := NativeToOvs(column, nativeElem)
+		if err != nil {
+			return nil, fmt.Errorf("Table %s, Column %s: Failed to generate OvS element. %s", tableName, name, err.Error())
+		}
+		ovsRow[name] = ovsElem
+	}
+	return ovsRow, nil
+}
+
+// defaultNativeValue returns the native representation of the default value of
+// a column (RFC7047 section 5.1)
+func defaultNativeValue(column *ColumnSchema) (interface{}, error) {
+	naType := NativeType(column)
+	switch column.Type {
+	case TypeEnum:
+		return nil, fmt.Errorf("enum columns have no default value")
+	case TypeUUID:
+		return defaultUUID, nil
+	case TypeSet:
+		set := reflect.MakeSlice(naType, 0, 1)
+		if column.TypeObj.Min > 0 {
+			// The set holds the default value of its key
+			key := reflect.Zero(naType.Elem())
+			if column.TypeObj.Key.Type == TypeUUID {
+				key = reflect.ValueOf(defaultUUID)
+			}
+			set = reflect.Append(set, key)
+		}
+		return set.Interface(), nil
+	case TypeMap:
+		return reflect.MakeMap(naType).Interface(), nil
+	default:
+		return reflect.Zero(naType).Interface(), nil
+	}
+}
+
+// isNilCollection returns whether elem is a nil slice or map given for a set or map column
+func isNilCollection(column *ColumnSchema, elem interface{}) bool {
+	if column.Type != TypeSet && column.Type != TypeMap {
+		return false
+	}
+	v := reflect.ValueOf(elem)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.IsNil()
+	}
+	return false
+}
+
 // NewCondition returns a valid condition to be used inside a Operation
-// It accepts native golang types (sets and maps)
-// TODO: check condition validity
-func (na NativeAPI) NewCondition(tableName, columnName, function string, value interface{}) ([]interface{}, error) {
+// It accepts native golang types (sets and maps), which must match the type of
+// the column, and checks that the function applies to the column
+func (na NativeAPI) NewCondition(tableName, columnName string, function ConditionFunction, value interface{}) ([]interface{}, error) {
+	if !IsValidFunction(function) {
+		return nil, fmt.Errorf("Invalid condition function %q", function)
+	}
 	column, err := na.schema.GetColumn(tableName, columnName)
 	if err != nil {
 		return nil, err
 	}
+	if err := validateFunction(columnName, column, function); err != nil {
+		return nil, err
+	}
+	if !isNativeValueOf(column, value) {
+		return nil, fmt.Errorf("Table %s, Column %s: expected a %s value but got %v (%T)",
+			tableName, columnName, NativeType(column), value, value)
+	}
 
 	ovsVal, err := NativeToOvs(column, value)
 	if err != nil {
@@ -121,15 +274,59 @@ func (na NativeAPI) NewCondition(tableName, columnName, function string, value i
 	return []interface{}{columnName, function, ovsVal}, nil
 }
 
+// NewIndexCondition returns the conditions (to be used as the Where field of an
+// Operation) matching the rows whose index columns have the given values
+// It accepts native golang types and requires one value per index column
+func (na NativeAPI) NewIndexCondition(tableName string, indexColumns []string, values ...interface{}) ([]interface{}, error) {
+	if len(indexColumns) == 0 {
+		return nil, fmt.Errorf("Table %s: empty index", tableName)
+	}
+	if len(values) != len(indexColumns) {
+		return nil, fmt.Errorf("Table %s: index %v has %d columns but %d values were given",
+			tableName, indexColumns, len(indexColumns), len(values))
+	}
+	conditions := make([]interface{}, 0, len(indexColumns))
+	for i, columnName := range indexColumns {
+		condition, err := na.NewCondition(tableName, columnName, FunctionEqual, values[i])
+		if err != nil {
+			return nil, fmt.Errorf("Table %s, Column %s: %s", tableName, columnName, err.Error())
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions, nil
+}
+
 // NewMutation returns a valid mutation to be used inside a Operation
-// It accepts native golang types (sets and maps)
-// TODO: check mutator validity
-func (na NativeAPI) NewMutation(tableName, columnName, mutator string, value interface{}) ([]interface{}, error) {
+// It accepts native golang types (sets and maps). To delete keys from a map column,
+// value can be either a map of the key-value pairs to delete or a slice of keys
+func (na NativeAPI) NewMutation(tableName, columnName string, mutator Mutator, value interface{}) ([]interface{}, error) {
+	if !IsValidMutator(mutator) {
+		return nil, fmt.Errorf("Invalid mutator %q", mutator)
+	}
 	column, err := na.schema.GetColumn(tableName, columnName)
 	if err != nil {
 		return nil, err
 	}
+	if err := validateMutator(columnName, column, mutator); err != nil {
+		return nil, err
+	}
 
+	// Arithmetic mutators take a single integer or real, even for sets
+	if mutator != MutatorInsert && mutator != MutatorDelete {
+		column = &ColumnSchema{Type: column.keyType()}
+	}
+
+	// Keys can be deleted from a map given either the key-value pairs (a map) or
+	// just the keys (a slice)
+	if column.Type == TypeMap && mutator == MutatorDelete && reflect.ValueOf(value).Kind() == reflect.Slice {
+		column = &ColumnSchema{
+			Type: TypeSet,
+			TypeObj: &ColumnType{
+				Key: column.TypeObj.Key,
+				Max: Unlimited,
+			},
+		}
+	}
 	ovsVal, err := NativeToOvs(column, value)
 	if err != nil {
 		return nil, err
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 var testSchema = []byte(`{
@@ -77,6 +79,15 @@ var testSchema = []byte(`{
             "max": 10
           }
         },
+        "aBoolSet": {
+          "type": {
+            "key": {
+              "type": "boolean"
+            },
+            "min": 0,
+            "max": 2
+          }
+        },
         "aEmptySet": {
           "type": {
             "key": {
@@ -114,13 +125,16 @@ var testSchema = []byte(`{
   }
 }`)
 
-//
 // When going Native -> OvS:
+//
 //	map -> *OvsMap
 //	slice -> *OvsSet
+//
 // However, when going OvS -> Native
+//
 //	OvsMap -> map
 //	OvsSet -> slice
+//
 // Perform indirection of ovs fields to be compared
 // with the ones that wre used initially
 func expectedOvs(in interface{}) interface{} {
@@ -141,6 +155,7 @@ func getNativeMap() map[string]interface{} {
 		"aMap":     aMap,
 		"aUUID":    aUUID0,
 		"aIntSet":  aIntSet,
+		"aBoolSet": aBoolSet,
 	}
 }
 
@@ -165,6 +180,8 @@ func GetOvsRow() Row {
 	}
 
 	ovsRow.Fields["aIntSet"] = *is
+	bs, _ := NewOvsSet(aBoolSet)
+	ovsRow.Fields["aBoolSet"] = *bs
 	return ovsRow
 }
 
@@ -201,9 +218,12 @@ func TestGetData(t *testing.T) {
 	if v, ok := data["aUUID"].(string); !ok || !reflect.DeepEqual(v, aUUID0) {
 		t.Errorf("invalid uuidvalue %v", v)
 	}
-	if v, ok := data["aIntSet"].([]int); !ok || !reflect.DeepEqual(v, aIntSet) {
+	if v, ok := data["aIntSet"].([]int64); !ok || !reflect.DeepEqual(v, aIntSet) {
 		t.Errorf("invalid integer set %v", v)
 	}
+	if v, ok := data["aBoolSet"].([]bool); !ok || !reflect.DeepEqual(v, aBoolSet) {
+		t.Errorf("invalid boolean set %v", v)
+	}
 }
 
 func TestNewRow(t *testing.T) {
@@ -229,3 +249,413 @@ func TestNewRow(t *testing.T) {
 
 	}
 }
+
+func TestEmptySetAndMap(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal(testSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	nf := NativeAPI{schema: &schema}
+
+	// nil sets and maps are left out of the row
+	row, err := nf.NewRow("TestTable", map[string]interface{}{
+		"aString":   aString,
+		"aEmptySet": []string(nil),
+		"aMap":      map[string]string(nil),
+	})
+	assert.Nil(t, err)
+	assert.NotContains(t, row, "aEmptySet")
+	assert.NotContains(t, row, "aMap")
+
+	// empty ones are sent as empty OVSDB sets and maps
+	row, err = nf.NewRow("TestTable", map[string]interface{}{
+		"aEmptySet": []string{},
+		"aMap":      map[string]string{},
+	})
+	assert.Nil(t, err)
+	b, err := json.Marshal(row)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"aEmptySet":["set",[]],"aMap":["map",[]]}`, string(b))
+
+	// and they are read back as empty, non-nil, slices and maps
+	var resultRow ResultRow
+	assert.Nil(t, json.Unmarshal(b, &resultRow))
+	data, err := nf.GetData("TestTable", resultRow)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, data["aEmptySet"])
+	assert.NotNil(t, data["aEmptySet"].([]string))
+	assert.Equal(t, map[string]string{}, data["aMap"])
+	assert.NotNil(t, data["aMap"].(map[string]string))
+}
+
+func TestLargeIntegers(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal(testSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	nf := NativeAPI{schema: &schema}
+
+	// 2^53 + 1 can't be represented by a float64
+	big := int64(1<<53 + 1)
+	row, err := nf.NewRow("TestTable", map[string]interface{}{
+		"aIntSet": []int64{big, -big},
+	})
+	assert.Nil(t, err)
+	b, err := json.Marshal(row)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"aIntSet":["set",[9007199254740993,-9007199254740993]]}`, string(b))
+
+	var resultRow ResultRow
+	assert.Nil(t, json.Unmarshal(b, &resultRow))
+	data, err := nf.GetData("TestTable", resultRow)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{big, -big}, data["aIntSet"])
+
+	// A single element is sent without the set notation
+	assert.Nil(t, json.Unmarshal([]byte(`{"aIntSet":9007199254740993}`), &resultRow))
+	data, err = nf.GetData("TestTable", resultRow)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{big}, data["aIntSet"])
+}
+
+func TestGetRowDataWithUUID(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal(testSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	nf := NativeAPI{schema: &schema}
+
+	ovsRow := GetOvsRow()
+	data, err := nf.GetRowDataWithUUID("TestTable", aUUID1, &ovsRow)
+	assert.Nil(t, err)
+	assert.Equal(t, aUUID1, data["_uuid"])
+	assert.Equal(t, aString, data["aString"])
+
+	data, err = nf.GetRowDataWithUUID("TestTable", aUUID1, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, data)
+	_, err = nf.GetRowDataWithUUID("NoSuchTable", aUUID1, &ovsRow)
+	assert.NotNil(t, err)
+}
+
+func TestGetResultData(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal(testSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	nf := NativeAPI{schema: &schema}
+
+	ovsRow := GetOvsRow()
+	result := OperationResult{Rows: []ResultRow{ovsRow.Fields, {"aString": "other"}}}
+	data, err := nf.GetResultData("TestTable", result)
+	assert.Nil(t, err)
+	assert.Len(t, data, 2)
+	assert.Equal(t, aString, data[0]["aString"])
+	assert.Equal(t, aMap, data[0]["aMap"])
+	assert.Equal(t, map[string]interface{}{"aString": "other"}, data[1])
+
+	data, err = nf.GetResultData("TestTable", OperationResult{})
+	assert.Nil(t, err)
+	assert.Len(t, data, 0)
+
+	result.Rows = append(result.Rows, ResultRow{"aString": 42})
+	_, err = nf.GetResultData("TestTable", result)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "Row 2")
+	}
+}
+
+func TestBuildInsertWithRef(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal(testSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	nf := NativeAPI{schema: &schema}
+
+	ops, namedUUID, err := nf.BuildInsertWithRef("TestTable", map[string]interface{}{"aString": aString}, "TestTable", "aUUIDSet", aUUID0)
+	assert.Nil(t, err)
+	assert.Nil(t, UUID{GoUUID: namedUUID}.Validate())
+	mutation, err := nf.NewMutation("TestTable", "aUUIDSet", MutatorInsert, []string{namedUUID})
+	assert.Nil(t, err)
+	assert.Equal(t, []Operation{
+		{
+			Op:       OperationInsert,
+			Table:    "TestTable",
+			Row:      map[string]interface{}{"aString": aString},
+			UUIDName: namedUUID,
+		},
+		{
+			Op:        OperationMutate,
+			Table:     "TestTable",
+			Mutations: []interface{}{mutation},
+			Where:     []interface{}{[]interface{}{"_uuid", FunctionEqual, UUID{GoUUID: aUUID0}}},
+		},
+	}, ops)
+
+	// Every call uses a new named-uuid
+	_, other, err := nf.BuildInsertWithRef("TestTable", map[string]interface{}{"aString": aString}, "TestTable", "aUUIDSet", aUUID0)
+	assert.Nil(t, err)
+	assert.NotEqual(t, namedUUID, other)
+
+	_, _, err = nf.BuildInsertWithRef("TestTable", map[string]interface{}{"aString": aString}, "TestTable", "aString", aUUID0)
+	assert.NotNil(t, err)
+	_, _, err = nf.BuildInsertWithRef("NoSuchTable", map[string]interface{}{}, "TestTable", "aUUIDSet", aUUID0)
+	assert.NotNil(t, err)
+}
+
+func TestGetDataPartialColumns(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal(testSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	nf := NativeAPI{schema: &schema}
+
+	emptySet, _ := NewOvsSet([]string{})
+	data, err := nf.GetData("TestTable", map[string]interface{}{
+		"aString": "",
+		"aSet":    *emptySet,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"aString": "",
+		"aSet":    []string{},
+	}, data)
+	_, ok := data["aMap"]
+	assert.False(t, ok)
+}
+
+func TestNewClearRow(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal(testSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	nf := NativeAPI{schema: &schema}
+
+	row, err := nf.NewClearRow("TestTable", "aString", "aFloat", "aUUID", "aSet", "aMap")
+	assert.Nil(t, err)
+	emptySet, _ := NewOvsSet([]string{})
+	emptyMap, _ := NewOvsMap(map[string]string{})
+	assert.Equal(t, map[string]interface{}{
+		"aString": "",
+		"aFloat":  0.0,
+		"aUUID":   UUID{GoUUID: "00000000-0000-0000-0000-000000000000"},
+		"aSet":    emptySet,
+		"aMap":    emptyMap,
+	}, row)
+
+	_, err = nf.NewClearRow("TestTable", "aEnum")
+	assert.NotNil(t, err)
+	_, err = nf.NewClearRow("TestTable", "noSuchColumn")
+	assert.NotNil(t, err)
+	_, err = nf.NewClearRow("NoSuchTable", "aString")
+	assert.NotNil(t, err)
+}
+
+func TestNewIndexCondition(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal(testSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	nf := NativeAPI{schema: &schema}
+
+	conditions, err := nf.NewIndexCondition("TestTable", []string{"aString", "aUUID"}, aString, aUUID0)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{
+		[]interface{}{"aString", "==", aString},
+		[]interface{}{"aUUID", "==", UUID{GoUUID: aUUID0}},
+	}, conditions)
+
+	_, err = nf.NewIndexCondition("TestTable", []string{"aString", "aUUID"}, aString)
+	assert.NotNil(t, err)
+	_, err = nf.NewIndexCondition("TestTable", []string{"aString"}, aString, aUUID0)
+	assert.NotNil(t, err)
+	_, err = nf.NewIndexCondition("TestTable", []string{"noSuchColumn"}, aString)
+	assert.NotNil(t, err)
+	_, err = nf.NewIndexCondition("TestTable", []string{"aString"}, 42)
+	assert.NotNil(t, err)
+	_, err = nf.NewIndexCondition("NoSuchTable", []string{"aString"}, aString)
+	assert.NotNil(t, err)
+}
+
+func TestInvalidMutatorAndFunction(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal(testSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	nf := NativeAPI{schema: &schema}
+
+	_, err := nf.NewCondition("TestTable", "aString", "=", aString)
+	assert.NotNil(t, err)
+	_, err = nf.NewCondition("TestTable", "aString", FunctionEqual, aString)
+	assert.Nil(t, err)
+	_, err = nf.NewMutation("TestTable", "aSet", "==", aSet)
+	assert.NotNil(t, err)
+	_, err = nf.NewMutation("TestTable", "aSet", MutatorInsert, aSet)
+	assert.Nil(t, err)
+}
+
+func TestNewMutationMapDelete(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal(testSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	nf := NativeAPI{schema: &schema}
+
+	// Delete given the keys
+	mutation, err := nf.NewMutation("TestTable", "aMap", MutatorDelete, []string{"key1", "key2"})
+	assert.Nil(t, err)
+	b, err := json.Marshal(mutation)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `["aMap","delete",["set",["key1","key2"]]]`, string(b))
+
+	// Delete given the key-value pairs
+	mutation, err = nf.NewMutation("TestTable", "aMap", MutatorDelete, map[string]string{"key1": "value1"})
+	assert.Nil(t, err)
+	b, err = json.Marshal(mutation)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `["aMap","delete",["map",[["key1","value1"]]]]`, string(b))
+
+	// Keys must have the key type of the map
+	_, err = nf.NewMutation("TestTable", "aMap", MutatorDelete, []int64{1})
+	assert.NotNil(t, err)
+	// Only pairs can be inserted
+	_, err = nf.NewMutation("TestTable", "aMap", MutatorInsert, []string{"key1"})
+	assert.NotNil(t, err)
+}
+
+func TestNewMutationNumeric(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal(testSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	nf := NativeAPI{schema: &schema}
+
+	for _, mutator := range []Mutator{MutatorAdd, MutatorSubtract, MutatorMultiply, MutatorDivide, MutatorModulo} {
+		// Integer sets are mutated element by element with a scalar
+		mutation, err := nf.NewMutation("TestTable", "aIntSet", mutator, int64(2))
+		assert.Nil(t, err, mutator)
+		b, err := json.Marshal(mutation)
+		assert.Nil(t, err)
+		assert.JSONEq(t, `["aIntSet","`+mutator+`",2]`, string(b))
+
+		// A float delta does not apply to an integer column
+		_, err = nf.NewMutation("TestTable", "aIntSet", mutator, 2.5)
+		assert.NotNil(t, err, mutator)
+
+		// Strings cannot be mutated arithmetically
+		_, err = nf.NewMutation("TestTable", "aString", mutator, "foo")
+		assert.NotNil(t, err, mutator)
+
+		// Reals accept all but modulo
+		_, err = nf.NewMutation("TestTable", "aFloat", mutator, 2.5)
+		if mutator == MutatorModulo {
+			assert.NotNil(t, err)
+		} else {
+			assert.Nil(t, err, mutator)
+		}
+	}
+}
+
+func TestNewConditionTypes(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal(testSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	nf := NativeAPI{schema: &schema}
+
+	_, err := nf.NewCondition("TestTable", "aString", FunctionEqual, aString)
+	assert.Nil(t, err)
+	_, err = nf.NewCondition("TestTable", "aUUID", FunctionEqual, UUID{GoUUID: aUUID0})
+	assert.Nil(t, err)
+	_, err = nf.NewCondition("TestTable", "aFloat", FunctionGreaterThan, 1)
+	assert.Nil(t, err)
+
+	// The error names the column and both types
+	_, err = nf.NewCondition("TestTable", "aString", FunctionEqual, UUID{GoUUID: aUUID0})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "aString")
+		assert.Contains(t, err.Error(), "string")
+		assert.Contains(t, err.Error(), "libovsdb.UUID")
+	}
+	_, err = nf.NewCondition("TestTable", "aFloat", FunctionEqual, "42")
+	assert.NotNil(t, err)
+	_, err = nf.NewCondition("TestTable", "aSet", FunctionIncludes, []int64{1})
+	assert.NotNil(t, err)
+	_, err = nf.NewCondition("TestTable", "aString", FunctionEqual, nil)
+	assert.NotNil(t, err)
+
+	// Ordering functions only apply to numbers
+	_, err = nf.NewCondition("TestTable", "aString", FunctionLessThan, aString)
+	assert.NotNil(t, err)
+}
+
+func TestNamedUUIDConditionsAndMutations(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal(testSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	nf := NativeAPI{schema: &schema}
+
+	// Named-uuids refer to rows inserted earlier in the same transaction
+	mutation, err := nf.NewMutation("TestTable", "aUUIDSet", MutatorInsert, []string{"gopher", aUUID0})
+	assert.Nil(t, err)
+	b, err := json.Marshal(mutation)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `["aUUIDSet","insert",["set",[["named-uuid","gopher"],["uuid","`+aUUID0+`"]]]]`, string(b))
+
+	mutation, err = nf.NewMutation("TestTable", "aUUIDSet", MutatorInsert, []UUID{{GoUUID: "gopher"}})
+	assert.Nil(t, err)
+	b, err = json.Marshal(mutation)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `["aUUIDSet","insert",["named-uuid","gopher"]]`, string(b))
+
+	condition, err := nf.NewCondition("TestTable", "aUUID", FunctionEqual, UUID{GoUUID: "gopher"})
+	assert.Nil(t, err)
+	b, err = json.Marshal(condition)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `["aUUID","==",["named-uuid","gopher"]]`, string(b))
+}
+
+func TestGetDataEnums(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal([]byte(`{
+	  "name": "EnumDB",
+	  "tables": {
+	    "T": {
+	      "columns": {
+	        "aEnum": {"type": {"key": {"type": "string", "enum": ["set", ["enum1", "enum2"]]}}},
+	        "anIntEnumSet": {"type": {"key": {"type": "integer", "enum": ["set", [1, 2]]}, "min": 0, "max": "unlimited"}},
+	        "aMap": {"type": {"key": "string", "value": {"type": "string", "enum": "on"}, "min": 0, "max": "unlimited"}}
+	      }
+	    }
+	  }
+	}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+	nf := NativeAPI{schema: &schema}
+
+	parse := func(s string) map[string]interface{} {
+		var row Row
+		if err := json.Unmarshal([]byte(s), &row); err != nil {
+			t.Fatal(err)
+		}
+		return row.Fields
+	}
+
+	data, err := nf.GetData("T", parse(`{"aEnum":"enum2","anIntEnumSet":["set",[1,2]],"aMap":["map",[["k","on"]]]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "enum2", data["aEnum"])
+	assert.Equal(t, []int64{1, 2}, data["anIntEnumSet"])
+	assert.Equal(t, map[string]string{"k": "on"}, data["aMap"])
+
+	for column, value := range map[string]string{
+		"aEnum":        `"enum3"`,
+		"anIntEnumSet": `["set",[1,3]]`,
+		"aMap":         `["map",[["k","off"]]]`,
+	} {
+		_, err := nf.GetData("T", parse(`{"`+column+`":`+value+`}`))
+		if assert.NotNil(t, err, column) {
+			assert.Contains(t, err.Error(), "Table T, Column "+column)
+		}
+	}
+}
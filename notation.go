@@ -1,11 +1,114 @@
 package libovsdb
 
-import "encoding/json"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// OpType is the type of an Operation (its "op" field)
+// OpType, Mutator and ConditionFunction are aliases of string, so that the
+// fields and parameters using them keep accepting plain strings, e.g: read from
+// a configuration. The constants below name the valid values, and
+// IsValidOperation, IsValidMutator and IsValidFunction check the other ones
+type OpType = string
+
+// Mutator is a mutator used in a Mutation
+type Mutator = string
+
+// ConditionFunction is a function used in a Condition
+type ConditionFunction = string
+
+const (
+	//OperationInsert inserts a row (RFC7047 section 5.2.1)
+	OperationInsert OpType = "insert"
+	//OperationSelect selects rows (RFC7047 section 5.2.2)
+	OperationSelect OpType = "select"
+	//OperationUpdate updates rows (RFC7047 section 5.2.3)
+	OperationUpdate OpType = "update"
+	//OperationMutate mutates rows (RFC7047 section 5.2.4)
+	OperationMutate OpType = "mutate"
+	//OperationDelete deletes rows (RFC7047 section 5.2.5)
+	OperationDelete OpType = "delete"
+	//OperationWait waits for a condition on rows (RFC7047 section 5.2.6)
+	OperationWait OpType = "wait"
+	//OperationCommit commits the transaction to disk (RFC7047 section 5.2.7)
+	OperationCommit OpType = "commit"
+	//OperationAbort aborts the transaction (RFC7047 section 5.2.8)
+	OperationAbort OpType = "abort"
+	//OperationComment adds a comment to the log (RFC7047 section 5.2.9)
+	OperationComment OpType = "comment"
+	//OperationAssert asserts a lock is owned (RFC7047 section 5.2.10)
+	OperationAssert OpType = "assert"
+
+	//MutatorAdd adds to an integer or real column
+	MutatorAdd Mutator = "+="
+	//MutatorSubtract subtracts from an integer or real column
+	MutatorSubtract Mutator = "-="
+	//MutatorMultiply multiplies an integer or real column
+	MutatorMultiply Mutator = "*="
+	//MutatorDivide divides an integer or real column
+	MutatorDivide Mutator = "/="
+	//MutatorModulo takes the remainder of an integer column
+	MutatorModulo Mutator = "%="
+	//MutatorInsert inserts elements into a set or map column
+	MutatorInsert Mutator = "insert"
+	//MutatorDelete deletes elements from a set or map column
+	MutatorDelete Mutator = "delete"
+
+	//FunctionLessThan is the '<' condition function
+	FunctionLessThan ConditionFunction = "<"
+	//FunctionLessThanOrEqual is the '<=' condition function
+	FunctionLessThanOrEqual ConditionFunction = "<="
+	//FunctionEqual is the '==' condition function
+	FunctionEqual ConditionFunction = "=="
+	//FunctionNotEqual is the '!=' condition function
+	FunctionNotEqual ConditionFunction = "!="
+	//FunctionGreaterThan is the '>' condition function
+	FunctionGreaterThan ConditionFunction = ">"
+	//FunctionGreaterThanOrEqual is the '>=' condition function
+	FunctionGreaterThanOrEqual ConditionFunction = ">="
+	//FunctionIncludes is the 'includes' condition function
+	FunctionIncludes ConditionFunction = "includes"
+	//FunctionExcludes is the 'excludes' condition function
+	FunctionExcludes ConditionFunction = "excludes"
+)
+
+// IsValidOperation returns whether op is an operation defined in RFC7047
+func IsValidOperation(op OpType) bool {
+	switch op {
+	case OperationInsert, OperationSelect, OperationUpdate, OperationMutate, OperationDelete,
+		OperationWait, OperationCommit, OperationAbort, OperationComment, OperationAssert:
+		return true
+	}
+	return false
+}
+
+// IsValidMutator returns whether mutator is a mutator defined in RFC7047
+func IsValidMutator(mutator Mutator) bool {
+	switch mutator {
+	case MutatorAdd, MutatorSubtract, MutatorMultiply, MutatorDivide, MutatorModulo,
+		MutatorInsert, MutatorDelete:
+		return true
+	}
+	return false
+}
+
+// IsValidFunction returns whether function is a condition function defined in RFC7047
+func IsValidFunction(function ConditionFunction) bool {
+	switch function {
+	case FunctionLessThan, FunctionLessThanOrEqual, FunctionEqual, FunctionNotEqual,
+		FunctionGreaterThan, FunctionGreaterThanOrEqual, FunctionIncludes, FunctionExcludes:
+		return true
+	}
+	return false
+}
 
 // Operation represents an operation according to RFC7047 section 5.2
 type Operation struct {
-	Op        string                   `json:"op"`
-	Table     string                   `json:"table"`
+	Op        OpType                   `json:"op"`
+	Table     string                   `json:"table,omitempty"`
 	Row       map[string]interface{}   `json:"row,omitempty"`
 	Rows      []map[string]interface{} `json:"rows,omitempty"`
 	Columns   []string                 `json:"columns,omitempty"`
@@ -14,17 +117,23 @@ type Operation struct {
 	Where     []interface{}            `json:"where,omitempty"`
 	Until     string                   `json:"until,omitempty"`
 	UUIDName  string                   `json:"uuid-name,omitempty"`
+	Comment   string                   `json:"comment,omitempty"`
 }
 
 // MarshalJSON marshalls 'Operation' to a byte array
 // For 'select' operations, we dont omit the 'Where' field
-// to allow selecting all rows of a table
+// to allow selecting all rows of a table. The 'Where' of the other operations
+// is only omitted when nil, so that an empty one selects all rows, and the
+// empty 'Row' of an 'insert' is sent to insert a row with the default values
 func (o Operation) MarshalJSON() ([]byte, error) {
 	type OpAlias Operation
 	switch o.Op {
-	case "select":
+	case OperationSelect, OperationUpdate, OperationMutate, OperationDelete, OperationWait:
 		where := o.Where
 		if where == nil {
+			if o.Op != OperationSelect {
+				break
+			}
 			where = make([]interface{}, 0, 0)
 		}
 		return json.Marshal(&struct {
@@ -34,13 +143,23 @@ func (o Operation) MarshalJSON() ([]byte, error) {
 			Where:   where,
 			OpAlias: (OpAlias)(o),
 		})
-	default:
+	case OperationInsert:
+		if o.Row == nil || len(o.Row) > 0 {
+			break
+		}
 		return json.Marshal(&struct {
+			Row map[string]interface{} `json:"row"`
 			OpAlias
 		}{
+			Row:     o.Row,
 			OpAlias: (OpAlias)(o),
 		})
 	}
+	return json.Marshal(&struct {
+		OpAlias
+	}{
+		OpAlias: (OpAlias)(o),
+	})
 }
 
 // MonitorRequests represents a group of monitor requests according to RFC7047
@@ -58,11 +177,24 @@ type MonitorRequest struct {
 }
 
 // MonitorSelect represents a monitor select according to RFC7047
+// The zero value selects every kind of update, which is the RFC default.
+// Otherwise only the kinds of updates set to true are selected
 type MonitorSelect struct {
-	Initial bool `json:"initial,omitempty"`
-	Insert  bool `json:"insert,omitempty"`
-	Delete  bool `json:"delete,omitempty"`
-	Modify  bool `json:"modify,omitempty"`
+	Initial bool `json:"initial"`
+	Insert  bool `json:"insert"`
+	Delete  bool `json:"delete"`
+	Modify  bool `json:"modify"`
+}
+
+// MarshalJSON marshals the select flags. Since the server considers the missing
+// flags to be true, they are all sent explicitly, except for the zero value
+// which is sent as an empty object
+func (ms MonitorSelect) MarshalJSON() ([]byte, error) {
+	if ms == (MonitorSelect{}) {
+		return []byte("{}"), nil
+	}
+	type monitorSelect MonitorSelect
+	return json.Marshal(monitorSelect(ms))
 }
 
 // TableUpdates is a collection of TableUpdate entries
@@ -80,6 +212,10 @@ type TableUpdate struct {
 }
 
 // RowUpdate represents a row update according to RFC7047
+// New is empty when the row is deleted and Old when it is inserted. When the row
+// is modified, New holds the monitored columns of the row after the change but
+// Old only holds the columns that changed, with their previous value: the full
+// previous row is Old applied on top of the previous New of the row
 type RowUpdate struct {
 	New Row `json:"new,omitempty"`
 	Old Row `json:"old,omitempty"`
@@ -91,13 +227,69 @@ type OvsdbError struct {
 	Details string `json:"details,omitempty"`
 }
 
+// Validate checks the operation against the schema of its database: the
+// operation must be known, have the members its type requires (e.g: the row of
+// an update or the mutations of a mutate) and only refer to existing tables and
+// columns, with valid conditions and mutations
+func (op Operation) Validate(schema *DatabaseSchema) error {
+	if err := schema.validateOperation(op); err != nil {
+		return fmt.Errorf("%s operation: %s", op.Op, err)
+	}
+	return nil
+}
+
+// validateMembers checks that the operation has the members required by its type
+func (op Operation) validateMembers() error {
+	switch op.Op {
+	case OperationInsert:
+		// Unlike the one of an update, the row of an insert may be empty to
+		// use the default values
+		if op.Row == nil {
+			return errors.New("missing row")
+		}
+	case OperationUpdate:
+		if len(op.Row) == 0 {
+			return errors.New("missing row")
+		}
+	case OperationMutate:
+		if len(op.Mutations) == 0 {
+			return errors.New("missing mutations")
+		}
+	case OperationWait:
+		if op.Until != FunctionEqual && op.Until != FunctionNotEqual {
+			return fmt.Errorf("invalid until %q", op.Until)
+		}
+	}
+	switch op.Op {
+	case OperationUpdate, OperationMutate, OperationDelete, OperationWait:
+		// An empty where selects all the rows, but it must be set
+		if op.Where == nil {
+			return errors.New("missing where")
+		}
+	}
+	return nil
+}
+
+// NewAbortOperation creates an abort operation as specified in RFC7047. It makes
+// the transaction fail, so that none of its operations take effect, which is
+// useful after wait operations to check the database without modifying it
+func NewAbortOperation() Operation {
+	return Operation{Op: OperationAbort}
+}
+
+// NewCommentOperation creates a comment operation as specified in RFC7047. The
+// comment is written to the log of ovsdb-server along with the transaction
+func NewCommentOperation(comment string) Operation {
+	return Operation{Op: OperationComment, Comment: comment}
+}
+
 // NewCondition creates a new condition as specified in RFC7047
-func NewCondition(column string, function string, value interface{}) []interface{} {
+func NewCondition(column string, function ConditionFunction, value interface{}) []interface{} {
 	return []interface{}{column, function, value}
 }
 
 // NewMutation creates a new mutation as specified in RFC7047
-func NewMutation(column string, mutator string, value interface{}) []interface{} {
+func NewMutation(column string, mutator Mutator, value interface{}) []interface{} {
 	return []interface{}{column, mutator, value}
 }
 
@@ -116,6 +308,37 @@ type OperationResult struct {
 	Rows    []ResultRow `json:"rows,omitempty"`
 }
 
+// InsertedUUID returns the UUID of the row inserted by the operation at opIndex
+// of a transaction, given the results of the transaction. It fails if there is
+// no such result, if the operation failed or if it is not an insert
+func InsertedUUID(results []OperationResult, opIndex int) (string, error) {
+	if opIndex < 0 || opIndex >= len(results) {
+		return "", fmt.Errorf("no result for operation %d: got %d results", opIndex, len(results))
+	}
+	result := results[opIndex]
+	if result.Error != "" {
+		return "", fmt.Errorf("operation %d failed: %s: %s", opIndex, result.Error, result.Details)
+	}
+	if result.UUID.validateUUID() != nil {
+		return "", fmt.Errorf("operation %d is not an insert", opIndex)
+	}
+	return result.UUID.GoUUID, nil
+}
+
+// RowCount returns the number of rows an update, mutate or delete operation has
+// matched (and so modified or deleted). It is 0 for the other operations
+func (r OperationResult) RowCount() int {
+	return r.Count
+}
+
+// unmarshalUseNumber is like json.Unmarshal but decodes numbers into json.Number
+// instead of float64, so that no precision is lost on 64-bit integers
+func unmarshalUseNumber(b []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
 func ovsSliceToGoNotation(val interface{}) (interface{}, error) {
 	switch val.(type) {
 	case []interface{}:
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"log"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestOpRowSerialization(t *testing.T) {
@@ -173,3 +175,61 @@ func TestNewMutation(t *testing.T) {
 		t.Error("mutation is not correctly formatted")
 	}
 }
+
+func TestNewAbortOperation(t *testing.T) {
+	abortStr, _ := json.Marshal(NewAbortOperation())
+	expected := `{"op":"abort"}`
+	if string(abortStr) != expected {
+		t.Error("Expected: ", expected, "Got", string(abortStr))
+	}
+}
+
+func TestValidOperationsMutatorsAndFunctions(t *testing.T) {
+	for _, op := range []OpType{"insert", "select", "update", "mutate", "delete", "wait", "commit", "abort", "comment", "assert"} {
+		assert.True(t, IsValidOperation(op), op)
+	}
+	assert.False(t, IsValidOperation("inserts"))
+
+	for _, mutator := range []Mutator{"+=", "-=", "*=", "/=", "%=", "insert", "delete"} {
+		assert.True(t, IsValidMutator(mutator), mutator)
+	}
+	assert.False(t, IsValidMutator("=="))
+
+	for _, function := range []ConditionFunction{"<", "<=", "==", "!=", ">", ">=", "includes", "excludes"} {
+		assert.True(t, IsValidFunction(function), function)
+	}
+	assert.False(t, IsValidFunction("="))
+	assert.False(t, IsValidFunction("insert"))
+}
+
+func TestOperationResultRowCount(t *testing.T) {
+	var results []OperationResult
+	err := json.Unmarshal([]byte(`[{"count": 0}, {"count": 5}, {"uuid": ["uuid", "2f77b348-9768-4866-b761-89d5177ecda0"]}]`), &results)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, results[0].RowCount())
+	assert.Equal(t, 5, results[1].RowCount())
+	assert.Equal(t, 0, results[2].RowCount())
+}
+
+func TestInsertedUUID(t *testing.T) {
+	var results []OperationResult
+	err := json.Unmarshal([]byte(`[{"uuid": ["uuid", "2f77b348-9768-4866-b761-89d5177ecda0"]}, {"count": 1}, {"error": "constraint violation", "details": "duplicate name"}]`), &results)
+	assert.Nil(t, err)
+
+	uuid, err := InsertedUUID(results, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "2f77b348-9768-4866-b761-89d5177ecda0", uuid)
+
+	_, err = InsertedUUID(results, 1)
+	assert.NotNil(t, err)
+	_, err = InsertedUUID(results, 2)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "duplicate name")
+	}
+	_, err = InsertedUUID(results, 3)
+	assert.NotNil(t, err)
+	_, err = InsertedUUID(results, -1)
+	assert.NotNil(t, err)
+	_, err = InsertedUUID(nil, 0)
+	assert.NotNil(t, err)
+}
@@ -100,7 +100,8 @@ func TestListDbs(t *testing.T) {
 		t.Error("Expected: 'Open_vSwitch'", reply)
 	}
 	var b bytes.Buffer
-	ovs.Schema[reply[0]].Print(&b)
+	schema, _ := ovs.SchemaFor(reply[0])
+	schema.Print(&b)
 	ovs.Disconnect()
 }
 
@@ -1,7 +1,5 @@
 package libovsdb
 
-import "encoding/json"
-
 // Row is a table Row according to RFC7047
 type Row struct {
 	Fields map[string]interface{}
@@ -11,7 +9,7 @@ type Row struct {
 func (r *Row) UnmarshalJSON(b []byte) (err error) {
 	r.Fields = make(map[string]interface{})
 	var raw map[string]interface{}
-	err = json.Unmarshal(b, &raw)
+	err = unmarshalUseNumber(b, &raw)
 	for key, val := range raw {
 		val, err = ovsSliceToGoNotation(val)
 		if err != nil {
@@ -29,7 +27,7 @@ type ResultRow map[string]interface{}
 func (r *ResultRow) UnmarshalJSON(b []byte) (err error) {
 	*r = make(map[string]interface{})
 	var raw map[string]interface{}
-	err = json.Unmarshal(b, &raw)
+	err = unmarshalUseNumber(b, &raw)
 	for key, val := range raw {
 		val, err = ovsSliceToGoNotation(val)
 		if err != nil {
@@ -72,6 +72,24 @@ func TestNewMonitorArgs(t *testing.T) {
 	}
 }
 
+func TestMonitorSelectMarshal(t *testing.T) {
+	tests := []struct {
+		sel      MonitorSelect
+		expected string
+	}{
+		{MonitorSelect{}, `{}`},
+		{MonitorSelect{Insert: true, Delete: true}, `{"initial":false,"insert":true,"delete":true,"modify":false}`},
+		{MonitorSelect{Initial: true, Insert: true, Delete: true, Modify: true}, `{"initial":true,"insert":true,"delete":true,"modify":true}`},
+	}
+	for _, tt := range tests {
+		selString, _ := json.Marshal(MonitorRequest{Select: tt.sel})
+		expected := `{"select":` + tt.expected + `}`
+		if string(selString) != expected {
+			t.Error("Expected: ", expected, " Got: ", string(selString))
+		}
+	}
+}
+
 func TestNewMonitorCancelArgs(t *testing.T) {
 	value := 1
 	args := NewMonitorCancelArgs(value)
@@ -105,13 +123,13 @@ func TestUpdate(t *testing.T) {
 	var reply interface{}
 
 	// Update notification should fail for arrays of size < 2
-	err := update(nil, []interface{}{"hello"}, &reply)
+	err := update(nil, []json.RawMessage{json.RawMessage(`"hello"`)}, &reply)
 	if err == nil {
 		t.Error("Expected: error for a dummy request")
 	}
 
 	// Update notification should fail if arg[1] is not map[string]map[string]RowUpdate type
-	err = update(nil, []interface{}{"hello", "gophers"}, &reply)
+	err = update(nil, []json.RawMessage{json.RawMessage(`"hello"`), json.RawMessage(`"gophers"`)}, &reply)
 	if err == nil {
 		t.Error("Expected: error for a dummy request")
 	}
@@ -121,8 +139,12 @@ func TestUpdate(t *testing.T) {
 	validRowUpdate := make(map[string]RowUpdate)
 	validRowUpdate["uuid"] = RowUpdate{}
 	validUpdate["table"] = validRowUpdate
+	b, err := json.Marshal(validUpdate)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	err = update(nil, []interface{}{"hello", validUpdate}, &reply)
+	err = update(nil, []json.RawMessage{json.RawMessage(`"hello"`), b}, &reply)
 	if err != nil {
 		t.Error(err)
 	}
@@ -4,14 +4,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"reflect"
+	"sort"
 	"strings"
 )
 
 // DatabaseSchema is a database schema according to RFC7047
 type DatabaseSchema struct {
-	Name    string                 `json:"name"`
-	Version string                 `json:"version"`
-	Tables  map[string]TableSchema `json:"tables"`
+	Name     string                 `json:"name"`
+	Version  string                 `json:"version"`
+	Checksum string                 `json:"cksum,omitempty"`
+	Tables   map[string]TableSchema `json:"tables"`
 }
 
 // GetColumn returns a Column Schema for a given table and column name
@@ -32,6 +35,188 @@ func (schema DatabaseSchema) GetColumn(tableName, columnName string) (*ColumnSch
 	return column, nil
 }
 
+// IsEphemeral returns whether a column of a table is ephemeral, i.e. its value
+// is not persisted and is lost when ovsdb-server restarts. Unknown tables and
+// columns are not ephemeral
+func (schema DatabaseSchema) IsEphemeral(tableName, columnName string) bool {
+	column, err := schema.GetColumn(tableName, columnName)
+	if err != nil {
+		return false
+	}
+	return column.Ephemeral
+}
+
+// CompatibleWith returns whether the schema can be used against a database with
+// the other schema (e.g: the one the server has), along with the differences
+// between both. They are compatible if they have the same major version and every
+// table and column of the schema exists in the other one with the same type.
+// Tables and columns only found in the other schema are reported but do not make
+// them incompatible
+func (schema DatabaseSchema) CompatibleWith(other *DatabaseSchema) (bool, []string) {
+	compatible := true
+	var diffs []string
+
+	if schema.Name != other.Name {
+		compatible = false
+		diffs = append(diffs, fmt.Sprintf("name: %s != %s", schema.Name, other.Name))
+	}
+	if schema.Version != other.Version {
+		if majorVersion(schema.Version) != majorVersion(other.Version) {
+			compatible = false
+		}
+		diffs = append(diffs, fmt.Sprintf("version: %s != %s", schema.Version, other.Version))
+	}
+
+	for _, tableName := range sortedTables(schema.Tables) {
+		table := schema.Tables[tableName]
+		otherTable, ok := other.Tables[tableName]
+		if !ok {
+			compatible = false
+			diffs = append(diffs, fmt.Sprintf("table %s: missing", tableName))
+			continue
+		}
+		for _, columnName := range sortedColumns(table.Columns) {
+			column := table.Columns[columnName]
+			otherColumn, ok := otherTable.Columns[columnName]
+			if !ok {
+				compatible = false
+				diffs = append(diffs, fmt.Sprintf("column %s.%s: missing", tableName, columnName))
+				continue
+			}
+			if column.Type != otherColumn.Type || !reflect.DeepEqual(column.TypeObj, otherColumn.TypeObj) {
+				compatible = false
+				diffs = append(diffs, fmt.Sprintf("column %s.%s: type %s != %s", tableName, columnName, column, otherColumn))
+			}
+		}
+		for _, columnName := range sortedColumns(otherTable.Columns) {
+			if _, ok := table.Columns[columnName]; !ok {
+				diffs = append(diffs, fmt.Sprintf("column %s.%s: added", tableName, columnName))
+			}
+		}
+	}
+	for _, tableName := range sortedTables(other.Tables) {
+		if _, ok := schema.Tables[tableName]; !ok {
+			diffs = append(diffs, fmt.Sprintf("table %s: added", tableName))
+		}
+	}
+	return compatible, diffs
+}
+
+// majorVersion returns the major number of a "x.y.z" schema version
+func majorVersion(version string) string {
+	return strings.SplitN(version, ".", 2)[0]
+}
+
+func sortedTables(tables map[string]TableSchema) []string {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedColumns(columns map[string]*ColumnSchema) []string {
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TableIndexes returns the column sets of the indexes of a table, starting with
+// the implicit index on _uuid
+func (schema DatabaseSchema) TableIndexes(tableName string) ([][]string, error) {
+	table, ok := schema.Tables[tableName]
+	if !ok {
+		return nil, NewErrNoTable(tableName)
+	}
+	return table.indexes(), nil
+}
+
+// HasTable returns whether the schema has a table with the given name
+func (schema DatabaseSchema) HasTable(tableName string) bool {
+	_, ok := schema.Tables[tableName]
+	return ok
+}
+
+// Columns returns the sorted names of the columns of a table. The implicit
+// _uuid and _version columns are not included
+func (schema DatabaseSchema) Columns(tableName string) ([]string, error) {
+	table, ok := schema.Tables[tableName]
+	if !ok {
+		return nil, NewErrNoTable(tableName)
+	}
+	return sortedColumns(table.Columns), nil
+}
+
+// ColumnChange holds the native values of a column that differs between two rows
+type ColumnChange struct {
+	Old interface{}
+	New interface{}
+}
+
+// DiffRows compares two rows of a table column by column using their native
+// values, and returns the columns whose values differ. A column missing from
+// one of the rows has a nil value. Sets are compared regardless of the order of
+// their elements. Columns that are not in the schema (e.g: _version) are ignored
+func (schema DatabaseSchema) DiffRows(tableName string, oldRow, newRow Row) (map[string]ColumnChange, error) {
+	table, ok := schema.Tables[tableName]
+	if !ok {
+		return nil, NewErrNoTable(tableName)
+	}
+	changes := make(map[string]ColumnChange)
+	for name, column := range table.Columns {
+		oldValue, err := nativeColumnValue(column, oldRow.Fields, name)
+		if err != nil {
+			return nil, fmt.Errorf("Table %s, Column %s: old row: %s", tableName, name, err)
+		}
+		newValue, err := nativeColumnValue(column, newRow.Fields, name)
+		if err != nil {
+			return nil, fmt.Errorf("Table %s, Column %s: new row: %s", tableName, name, err)
+		}
+		if !nativeEqual(column, oldValue, newValue) {
+			changes[name] = ColumnChange{Old: oldValue, New: newValue}
+		}
+	}
+	return changes, nil
+}
+
+// nativeColumnValue returns the native value of a column of a row, or nil if the
+// row does not have it
+func nativeColumnValue(column *ColumnSchema, fields map[string]interface{}, name string) (interface{}, error) {
+	ovsElem, ok := fields[name]
+	if !ok {
+		return nil, nil
+	}
+	return OvsToNative(column, ovsElem)
+}
+
+// nativeEqual returns whether two native values of a column are equal. The
+// elements of a set may be in any order
+func nativeEqual(column *ColumnSchema, a, b interface{}) bool {
+	if column.Type != TypeSet || a == nil || b == nil {
+		return reflect.DeepEqual(a, b)
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Len() != vb.Len() {
+		return false
+	}
+	counts := make(map[interface{}]int, va.Len())
+	for i := 0; i < va.Len(); i++ {
+		counts[va.Index(i).Interface()]++
+	}
+	for i := 0; i < vb.Len(); i++ {
+		elem := vb.Index(i).Interface()
+		if counts[elem] == 0 {
+			return false
+		}
+		counts[elem]--
+	}
+	return true
+}
+
 // Print will print the contents of the DatabaseSchema
 func (schema DatabaseSchema) Print(w io.Writer) {
 	fmt.Fprintf(w, "%s, (%s)\n", schema.Name, schema.Version)
@@ -43,9 +228,54 @@ func (schema DatabaseSchema) Print(w io.Writer) {
 	}
 }
 
+// PrintJSON writes the schema back in its JSON representation
+func (schema DatabaseSchema) PrintJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// PrintDot writes a Graphviz graph of the schema where tables are nodes and
+// columns holding references to other tables are edges. Weak references are
+// drawn as dashed lines
+func (schema DatabaseSchema) PrintDot(w io.Writer) {
+	fmt.Fprintf(w, "digraph %q {\n", schema.Name)
+	for _, tableName := range sortedTables(schema.Tables) {
+		fmt.Fprintf(w, "\t%q;\n", tableName)
+	}
+	for _, tableName := range sortedTables(schema.Tables) {
+		table := schema.Tables[tableName]
+		for _, columnName := range sortedColumns(table.Columns) {
+			column := table.Columns[columnName]
+			if column.TypeObj == nil {
+				continue
+			}
+			for _, ref := range []*BaseType{column.TypeObj.Key, column.TypeObj.Value} {
+				if ref == nil || ref.RefTable == "" {
+					continue
+				}
+				style := "solid"
+				if ref.RefType == Weak {
+					style = "dashed"
+				}
+				fmt.Fprintf(w, "\t%q -> %q [label=%q, style=%s];\n", tableName, ref.RefTable, columnName, style)
+			}
+		}
+	}
+	fmt.Fprintf(w, "}\n")
+}
+
 // Basic validation for operations against Database Schema
 func (schema DatabaseSchema) validateOperations(operations ...Operation) bool {
 	for _, op := range operations {
+		switch op.Op {
+		case OperationCommit, OperationAbort, OperationComment, OperationAssert:
+			// These operations do not refer to a table
+			continue
+		}
 		table, ok := schema.Tables[op.Table]
 		if ok {
 			for column := range op.Row {
@@ -78,6 +308,271 @@ func (schema DatabaseSchema) validateOperations(operations ...Operation) bool {
 	return true
 }
 
+// ValidateTransaction checks the operations of a transaction against the schema
+// before they are sent: the operations must be known, refer to existing tables
+// and columns, use condition functions and mutators that are valid for the
+// column types, only modify mutable columns and insert rows with all the
+// required columns. Named UUIDs must be valid identifiers and unique within the
+// transaction. The returned error refers to the index of the faulty operation
+func (schema DatabaseSchema) ValidateTransaction(operations []Operation) error {
+	namedUUIDs := make(map[string]int)
+	for i, op := range operations {
+		if err := schema.validateOperation(op); err != nil {
+			return fmt.Errorf("operation %d (%s): %s", i, op.Op, err)
+		}
+		if op.UUIDName == "" {
+			continue
+		}
+		if err := validateNamedUUID(op.UUIDName); err != nil {
+			return fmt.Errorf("operation %d (%s): %s", i, op.Op, err)
+		}
+		if j, ok := namedUUIDs[op.UUIDName]; ok {
+			return fmt.Errorf("operation %d (%s): named-uuid %q already used by operation %d", i, op.Op, op.UUIDName, j)
+		}
+		namedUUIDs[op.UUIDName] = i
+	}
+	return nil
+}
+
+func (schema DatabaseSchema) validateOperation(op Operation) error {
+	if !IsValidOperation(op.Op) {
+		return fmt.Errorf("unknown operation")
+	}
+	switch op.Op {
+	case OperationCommit, OperationAbort, OperationComment, OperationAssert:
+		return nil
+	}
+
+	if err := op.validateMembers(); err != nil {
+		return err
+	}
+
+	table, ok := schema.Tables[op.Table]
+	if !ok {
+		return fmt.Errorf("table %q not found in schema", op.Table)
+	}
+	rows := op.Rows
+	if op.Row != nil {
+		rows = append(rows, op.Row)
+	}
+	for _, row := range rows {
+		for name := range row {
+			column, err := table.column(name)
+			if err != nil {
+				return err
+			}
+			if op.Op == OperationUpdate && !column.Mutable {
+				return fmt.Errorf("column %q is not mutable", name)
+			}
+		}
+	}
+	for _, name := range op.Columns {
+		if _, err := table.column(name); err != nil {
+			return err
+		}
+	}
+	for _, condition := range op.Where {
+		if err := table.validateCondition(condition); err != nil {
+			return err
+		}
+	}
+	for _, mutation := range op.Mutations {
+		if err := table.validateMutation(mutation); err != nil {
+			return err
+		}
+	}
+	if op.Op == OperationInsert {
+		var missing []string
+		for name, column := range table.Columns {
+			if _, ok := op.Row[name]; !ok && column.isRequired() {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			return fmt.Errorf("missing required columns %v", missing)
+		}
+	}
+	return nil
+}
+
+// indexes returns the column sets of the indexes of the table, including _uuid
+func (table TableSchema) indexes() [][]string {
+	indexes := make([][]string, 0, len(table.Indexes)+1)
+	indexes = append(indexes, []string{"_uuid"})
+	return append(indexes, table.Indexes...)
+}
+
+// HasIndex returns whether the table has an index on exactly the given columns,
+// in any order. Every table has an index on _uuid
+func (table TableSchema) HasIndex(columns ...string) bool {
+	for _, index := range table.indexes() {
+		if len(index) != len(columns) {
+			continue
+		}
+		found := true
+		for _, column := range columns {
+			found = false
+			for _, indexColumn := range index {
+				if column == indexColumn {
+					found = true
+					break
+				}
+			}
+			if !found {
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// column returns the schema of a column of the table, including the _uuid and
+// _version columns every table has
+func (table TableSchema) column(name string) (*ColumnSchema, error) {
+	if name == "_uuid" || name == "_version" {
+		return &ColumnSchema{Type: TypeUUID}, nil
+	}
+	column, ok := table.Columns[name]
+	if !ok {
+		return nil, fmt.Errorf("column %q not found in schema", name)
+	}
+	return column, nil
+}
+
+// validateCondition checks a [column, function, value] condition
+func (table TableSchema) validateCondition(condition interface{}) error {
+	c, ok := condition.([]interface{})
+	if !ok || len(c) != 3 {
+		return fmt.Errorf("invalid condition %v", condition)
+	}
+	name, ok := c[0].(string)
+	if !ok {
+		return fmt.Errorf("invalid condition column %v", c[0])
+	}
+	column, err := table.column(name)
+	if err != nil {
+		return err
+	}
+	function, ok := c[1].(string)
+	if !ok || !IsValidFunction(function) {
+		return fmt.Errorf("invalid condition function %v", c[1])
+	}
+	return validateFunction(name, column, function)
+}
+
+// validateFunction checks that the condition function can be applied to the
+// column: the ordering functions only apply to integers and reals
+func validateFunction(name string, column *ColumnSchema, function ConditionFunction) error {
+	switch function {
+	case FunctionLessThan, FunctionLessThanOrEqual, FunctionGreaterThan, FunctionGreaterThanOrEqual:
+		if column.Type != TypeInteger && column.Type != TypeReal {
+			return fmt.Errorf("condition function %s is not valid for column %q of type %s", function, name, column.Type)
+		}
+	}
+	return nil
+}
+
+// validateMutation checks a [column, mutator, value] mutation
+func (table TableSchema) validateMutation(mutation interface{}) error {
+	m, ok := mutation.([]interface{})
+	if !ok || len(m) != 3 {
+		return fmt.Errorf("invalid mutation %v", mutation)
+	}
+	name, ok := m[0].(string)
+	if !ok {
+		return fmt.Errorf("invalid mutation column %v", m[0])
+	}
+	column, err := table.column(name)
+	if err != nil {
+		return err
+	}
+	if !column.Mutable || name == "_uuid" || name == "_version" {
+		return fmt.Errorf("column %q is not mutable", name)
+	}
+	mutator, ok := m[1].(string)
+	if !ok || !IsValidMutator(mutator) {
+		return fmt.Errorf("invalid mutator %v", m[1])
+	}
+	return validateMutator(name, column, mutator)
+}
+
+// validateMutator checks that the mutator can be applied to the column: insert
+// and delete to sets and maps, and the arithmetic mutators to integers and
+// reals, or sets of them (modulo only to integers)
+func validateMutator(name string, column *ColumnSchema, mutator Mutator) error {
+	keyType := column.keyType()
+	switch mutator {
+	case MutatorInsert, MutatorDelete:
+		if column.Type != TypeSet && column.Type != TypeMap {
+			return fmt.Errorf("mutator %s is not valid for column %q of type %s", mutator, name, column.Type)
+		}
+	case MutatorModulo:
+		if keyType != TypeInteger || column.Type == TypeMap {
+			return fmt.Errorf("mutator %s is not valid for column %q of type %s", mutator, name, column.Type)
+		}
+	default:
+		if (keyType != TypeInteger && keyType != TypeReal) || column.Type == TypeMap {
+			return fmt.Errorf("mutator %s is not valid for column %q of type %s", mutator, name, column.Type)
+		}
+	}
+	return nil
+}
+
+// keyType returns the atomic type of the column, or of its keys for sets and maps
+func (column *ColumnSchema) keyType() ExtendedType {
+	if column.TypeObj != nil && column.TypeObj.Key != nil {
+		return column.TypeObj.Key.Type
+	}
+	return column.Type
+}
+
+// isRequired returns whether the column must be given a value on insert, that is,
+// whether its default value is not valid. Columns that cannot be empty default
+// to the default atoms of their key (and value) type, which may not satisfy the
+// constraints of the type (see acceptsDefault)
+func (column *ColumnSchema) isRequired() bool {
+	if column.TypeObj == nil || column.TypeObj.Min < 1 {
+		return false
+	}
+	return !column.TypeObj.Key.acceptsDefault() || !column.TypeObj.Value.acceptsDefault()
+}
+
+// acceptsDefault returns whether the default atom of the base type (0, 0.0, false,
+// "" or the all-zeros UUID) satisfies its constraints. It does not for references
+// to another table, since the default UUID does not refer to any row, for ranges
+// and enums that exclude it and for strings with a minimum length
+func (bt *BaseType) acceptsDefault() bool {
+	if bt == nil {
+		return true
+	}
+	switch bt.Type {
+	case TypeUUID:
+		if bt.RefTable != "" {
+			return false
+		}
+	case TypeInteger:
+		if bt.MinInteger > 0 || bt.MaxInteger < 0 {
+			return false
+		}
+	case TypeReal:
+		if bt.MinReal > 0 || bt.MaxReal < 0 {
+			return false
+		}
+	case TypeString:
+		if bt.MinLength > 0 {
+			return false
+		}
+	}
+	if len(bt.Enum) == 0 || bt.Type == TypeUUID {
+		return true
+	}
+	return validateEnum(bt, reflect.Zero(nativeTypeFromBasic(bt.Type)).Interface()) == nil
+}
+
 // TableSchema is a table schema according to RFC7047
 type TableSchema struct {
 	Columns map[string]*ColumnSchema `json:"columns"`
@@ -212,13 +707,86 @@ func (column *ColumnSchema) String() string {
 	return fmt.Sprintf(strings.Join([]string{typeStr, flagStr}, " "))
 }
 
+// MarshalJSON marshalls a column into its RFC7047 json representation
+func (column ColumnSchema) MarshalJSON() ([]byte, error) {
+	// ColumnJSON represents the known json values for a Column
+	type ColumnJSON struct {
+		Type      interface{} `json:"type"`
+		Ephemeral bool        `json:"ephemeral,omitempty"`
+		Mutable   bool        `json:"mutable"`
+	}
+	colJSON := ColumnJSON{
+		Type:      column.Type,
+		Ephemeral: column.Ephemeral,
+		Mutable:   column.Mutable,
+	}
+	if column.TypeObj != nil {
+		colJSON.Type = column.TypeObj
+	}
+	return json.Marshal(colJSON)
+}
+
+// MarshalJSON marshalls a type object into its RFC7047 json representation
+func (ct ColumnType) MarshalJSON() ([]byte, error) {
+	// ColumnTypeJSON represents the known json values for a ColumnType
+	type ColumnTypeJSON struct {
+		Key   *BaseType   `json:"key"`
+		Value *BaseType   `json:"value,omitempty"`
+		Min   int         `json:"min"`
+		Max   interface{} `json:"max"`
+	}
+	ctJSON := ColumnTypeJSON{
+		Key:   ct.Key,
+		Value: ct.Value,
+		Min:   ct.Min,
+		Max:   ct.Max,
+	}
+	if ct.Max == Unlimited {
+		ctJSON.Max = "unlimited"
+	}
+	return json.Marshal(ctJSON)
+}
+
+// MarshalJSON marshalls a base type into its RFC7047 json representation
+func (bt BaseType) MarshalJSON() ([]byte, error) {
+	// BaseTypeJSON represents the known json values for a BaseType
+	type BaseTypeJSON struct {
+		Type       string        `json:"type"`
+		Enum       []interface{} `json:"enum,omitempty"`
+		MinReal    float64       `json:"minReal,omitempty"`
+		MaxReal    float64       `json:"maxReal,omitempty"`
+		MinInteger int           `json:"minInteger,omitempty"`
+		MaxInteger int           `json:"maxInteger,omitempty"`
+		MinLength  int           `json:"minLength,omitempty"`
+		MaxLength  int           `json:"maxLength,omitempty"`
+		RefTable   string        `json:"refTable,omitempty"`
+		RefType    RefType       `json:"refType,omitempty"`
+	}
+	btJSON := BaseTypeJSON{
+		Type:       bt.Type,
+		MinReal:    bt.MinReal,
+		MaxReal:    bt.MaxReal,
+		MinInteger: bt.MinInteger,
+		MaxInteger: bt.MaxInteger,
+		MinLength:  bt.MinLength,
+		MaxLength:  bt.MaxLength,
+		RefTable:   bt.RefTable,
+		RefType:    bt.RefType,
+	}
+	// enum is encoded as an OVSDB set
+	if len(bt.Enum) > 0 {
+		btJSON.Enum = []interface{}{"set", bt.Enum}
+	}
+	return json.Marshal(btJSON)
+}
+
 // UnmarshalJSON unmarshalls a json-formatted column
 func (column *ColumnSchema) UnmarshalJSON(data []byte) error {
 	// ColumnJSON represents the known json values for a Column
 	type ColumnJSON struct {
 		TypeRawMsg json.RawMessage `json:"type"`
 		Ephemeral  bool            `json:"ephemeral,omitempty"`
-		Mutable    bool            `json:"mutable,omitempty"`
+		Mutable    *bool           `json:"mutable,omitempty"`
 	}
 	var colJSON ColumnJSON
 
@@ -228,7 +796,8 @@ func (column *ColumnSchema) UnmarshalJSON(data []byte) error {
 	}
 
 	column.Ephemeral = colJSON.Ephemeral
-	column.Mutable = colJSON.Mutable
+	// Columns are mutable unless stated otherwise
+	column.Mutable = colJSON.Mutable == nil || *colJSON.Mutable
 
 	// 'type' can be a string or an object, let's figure it out
 	var typeString string
@@ -276,7 +845,8 @@ func (column *ColumnSchema) UnmarshalJSON(data []byte) error {
 	// ease of use.
 
 	// 'max' can be an integer or the string "unlimmited". To simplify, use -1
-	// as unlimited
+	// as unlimited. Like for any other member, when 'max' is given more than
+	// once the last value is used, as ovsdb-server does
 	if colTypeJSON.MaxRawMsg != nil {
 		var maxString string
 		if err := json.Unmarshal(*colTypeJSON.MaxRawMsg, &maxString); err == nil {
@@ -287,9 +857,19 @@ func (column *ColumnSchema) UnmarshalJSON(data []byte) error {
 			}
 		} else if err := json.Unmarshal(*colTypeJSON.MaxRawMsg, &column.TypeObj.Max); err != nil {
 			return fmt.Errorf("Cannot parse max field: %s", err)
+		} else if column.TypeObj.Max < 1 {
+			return fmt.Errorf("Invalid max value %d", column.TypeObj.Max)
 		}
 	}
 	column.TypeObj.Min = colTypeJSON.Min
+	// RFC 7047 requires min to be 0 or 1 and max to be at least 1 and min, so
+	// that a column with min 0 and max 1 is always an optional value (a set)
+	if column.TypeObj.Min != 0 && column.TypeObj.Min != 1 {
+		return fmt.Errorf("Invalid min value %d", column.TypeObj.Min)
+	}
+	if column.TypeObj.Max != Unlimited && column.TypeObj.Max < column.TypeObj.Min {
+		return fmt.Errorf("Invalid max value %d", column.TypeObj.Max)
+	}
 
 	// 'key' and 'value' can, themselves, be a string or a BaseType.
 	// key='<atomic_type>' is equivalent to 'key': {'type': '<atomic_type>'}
@@ -3,6 +3,7 @@ package libovsdb
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"encoding/json"
@@ -49,16 +50,20 @@ func TestSchema(t *testing.T) {
 					"atomicTable": {
 						Columns: map[string]*ColumnSchema{
 							"str": {
-								Type: TypeString,
+								Type:    TypeString,
+								Mutable: true,
 							},
 							"int": {
-								Type: TypeInteger,
+								Type:    TypeInteger,
+								Mutable: true,
 							},
 							"float": {
-								Type: TypeReal,
+								Type:    TypeReal,
+								Mutable: true,
 							},
 							"uuid": {
-								Type: TypeUUID,
+								Type:    TypeUUID,
+								Mutable: true,
 							},
 						},
 					},
@@ -123,7 +128,8 @@ func TestSchema(t *testing.T) {
 					"setTable": {
 						Columns: map[string]*ColumnSchema{
 							"single": {
-								Type: TypeString,
+								Type:    TypeString,
+								Mutable: true,
 								TypeObj: &ColumnType{
 									Key: &BaseType{Type: "string"},
 									Max: 1,
@@ -131,7 +137,8 @@ func TestSchema(t *testing.T) {
 								},
 							},
 							"oneElem": {
-								Type: TypeSet,
+								Type:    TypeSet,
+								Mutable: true,
 								TypeObj: &ColumnType{
 									Key: &BaseType{Type: "uuid"},
 									Max: 1,
@@ -139,7 +146,8 @@ func TestSchema(t *testing.T) {
 								},
 							},
 							"multipleElem": {
-								Type: TypeSet,
+								Type:    TypeSet,
+								Mutable: true,
 								TypeObj: &ColumnType{
 									Key: &BaseType{Type: "real"},
 									Max: 2,
@@ -147,7 +155,8 @@ func TestSchema(t *testing.T) {
 								},
 							},
 							"unlimitedElem": {
-								Type: TypeSet,
+								Type:    TypeSet,
+								Mutable: true,
 								TypeObj: &ColumnType{
 									Key: &BaseType{Type: "integer"},
 									Max: Unlimited,
@@ -155,7 +164,8 @@ func TestSchema(t *testing.T) {
 								},
 							},
 							"enumSet": {
-								Type: TypeSet,
+								Type:    TypeSet,
+								Mutable: true,
 								TypeObj: &ColumnType{
 									Key: &BaseType{
 										Type: "string",
@@ -223,7 +233,8 @@ func TestSchema(t *testing.T) {
 					"mapTable": {
 						Columns: map[string]*ColumnSchema{
 							"str_str": {
-								Type: TypeMap,
+								Type:    TypeMap,
+								Mutable: true,
 								TypeObj: &ColumnType{
 									Key:   &BaseType{Type: "string"},
 									Value: &BaseType{Type: "string"},
@@ -232,7 +243,8 @@ func TestSchema(t *testing.T) {
 								},
 							},
 							"str_int": {
-								Type: TypeMap,
+								Type:    TypeMap,
+								Mutable: true,
 								TypeObj: &ColumnType{
 									Key:   &BaseType{Type: "string"},
 									Value: &BaseType{Type: "integer"},
@@ -241,7 +253,8 @@ func TestSchema(t *testing.T) {
 								},
 							},
 							"int_real": {
-								Type: TypeMap,
+								Type:    TypeMap,
+								Mutable: true,
 								TypeObj: &ColumnType{
 									Key:   &BaseType{Type: "integer"},
 									Value: &BaseType{Type: "real"},
@@ -250,7 +263,8 @@ func TestSchema(t *testing.T) {
 								},
 							},
 							"str_uuid": {
-								Type: TypeMap,
+								Type:    TypeMap,
+								Mutable: true,
 								TypeObj: &ColumnType{
 									Key:   &BaseType{Type: "string"},
 									Value: &BaseType{Type: "uuid"},
@@ -259,7 +273,8 @@ func TestSchema(t *testing.T) {
 								},
 							},
 							"str_enum": {
-								Type: TypeMap,
+								Type:    TypeMap,
+								Mutable: true,
 								TypeObj: &ColumnType{
 									Key: &BaseType{
 										Type: "string",
@@ -350,3 +365,595 @@ func TestSchema(t *testing.T) {
 	}
 
 }
+
+func TestSchemaMarshalRoundTrip(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal(testSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result DatabaseSchema
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(schema, result) {
+		t.Errorf("Schema changed after marshalling. Expected %v, got %v", schema, result)
+	}
+}
+
+func TestValidateTransaction(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal([]byte(`{
+  "name": "TestDB",
+  "tables": {
+    "Bridge": {
+      "columns": {
+        "name": {"type": "string", "mutable": false},
+        "count": {"type": "integer"},
+        "ports": {"type": {"key": {"type": "uuid", "refTable": "Port"}, "min": 1, "max": "unlimited"}},
+        "external_ids": {"type": {"key": "string", "value": "string", "min": 0, "max": "unlimited"}}
+      }
+    },
+    "Port": {
+      "columns": {
+        "name": {"type": "string"}
+      }
+    }
+  }
+}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	ports := UUID{GoUUID: "port"}
+	where := []interface{}{NewCondition("_uuid", FunctionEqual, ports)}
+	tests := []struct {
+		name  string
+		ops   []Operation
+		valid bool
+	}{
+		{
+			name: "valid transaction",
+			ops: []Operation{
+				{Op: OperationInsert, Table: "Port", Row: map[string]interface{}{"name": "p0"}, UUIDName: "port"},
+				{Op: OperationInsert, Table: "Bridge", Row: map[string]interface{}{"name": "br0", "ports": ports}},
+				{Op: OperationMutate, Table: "Bridge",
+					Where:     []interface{}{NewCondition("count", FunctionGreaterThan, 1)},
+					Mutations: []interface{}{NewMutation("count", MutatorAdd, 1), NewMutation("external_ids", MutatorDelete, "foo")}},
+				{Op: OperationSelect, Table: "Bridge", Where: []interface{}{NewCondition("_uuid", FunctionEqual, ports)}},
+				{Op: OperationComment},
+			},
+			valid: true,
+		},
+		{
+			name: "unknown operation",
+			ops:  []Operation{{Op: "inserts", Table: "Port"}},
+		},
+		{
+			name: "unknown table",
+			ops:  []Operation{{Op: OperationSelect, Table: "Foo"}},
+		},
+		{
+			name: "unknown column",
+			ops:  []Operation{{Op: OperationSelect, Table: "Port", Columns: []string{"foo"}}},
+		},
+		{
+			name: "invalid condition function",
+			ops:  []Operation{{Op: OperationSelect, Table: "Port", Where: []interface{}{NewCondition("name", "=", "p0")}}},
+		},
+		{
+			name: "inequality on a string column",
+			ops:  []Operation{{Op: OperationSelect, Table: "Port", Where: []interface{}{NewCondition("name", FunctionLessThan, "p0")}}},
+		},
+		{
+			name: "update of an immutable column",
+			ops:  []Operation{{Op: OperationUpdate, Table: "Bridge", Row: map[string]interface{}{"name": "br1"}}},
+		},
+		{
+			name: "mutation of an immutable column",
+			ops:  []Operation{{Op: OperationMutate, Table: "Bridge", Where: where, Mutations: []interface{}{NewMutation("name", MutatorInsert, "x")}}},
+		},
+		{
+			name: "arithmetic mutation of a map",
+			ops:  []Operation{{Op: OperationMutate, Table: "Bridge", Where: where, Mutations: []interface{}{NewMutation("external_ids", MutatorAdd, 1)}}},
+		},
+		{
+			name: "insert mutation of an integer",
+			ops:  []Operation{{Op: OperationMutate, Table: "Bridge", Where: where, Mutations: []interface{}{NewMutation("count", MutatorInsert, 1)}}},
+		},
+		{
+			name: "update without a row",
+			ops:  []Operation{{Op: OperationUpdate, Table: "Bridge", Where: where}},
+		},
+		{
+			name: "mutate without mutations",
+			ops:  []Operation{{Op: OperationMutate, Table: "Bridge", Where: where}},
+		},
+		{
+			name: "mutate without where",
+			ops:  []Operation{{Op: OperationMutate, Table: "Bridge", Mutations: []interface{}{NewMutation("count", MutatorAdd, 1)}}},
+		},
+		{
+			name: "wait with an invalid until",
+			ops:  []Operation{{Op: OperationWait, Table: "Bridge", Columns: []string{"name"}, Until: "<"}},
+		},
+		{
+			name: "insert without a required column",
+			ops:  []Operation{{Op: OperationInsert, Table: "Bridge", Row: map[string]interface{}{"name": "br0"}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := schema.ValidateTransaction(test.ops)
+			if test.valid && err != nil {
+				t.Errorf("Expected the transaction to be valid, got %s", err)
+			}
+			if !test.valid && err == nil {
+				t.Error("Expected the transaction to be invalid")
+			}
+		})
+	}
+
+	// Errors refer to the faulty operation
+	err := schema.ValidateTransaction([]Operation{{Op: OperationComment}, {Op: OperationSelect, Table: "Foo"}})
+	if err == nil || !strings.HasPrefix(err.Error(), "operation 1 (select)") {
+		t.Errorf("Expected an error about operation 1, got %v", err)
+	}
+}
+
+func TestInsertRequiredColumns(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal([]byte(`{
+  "name": "TestDB",
+  "tables": {
+    "T": {
+      "columns": {
+        "name": {"type": "string"},
+        "optional": {"type": {"key": {"type": "uuid", "refTable": "T"}, "min": 0, "max": 1}},
+        "ref": {"type": {"key": {"type": "uuid", "refTable": "T"}}},
+        "enum": {"type": {"key": {"type": "string", "enum": ["set", ["a", "b"]]}}},
+        "enumWithDefault": {"type": {"key": {"type": "integer", "enum": ["set", [0, 1]]}}},
+        "priority": {"type": {"key": {"type": "integer", "minInteger": 1, "maxInteger": 10}}},
+        "level": {"type": {"key": {"type": "integer", "minInteger": 0, "maxInteger": 10}}},
+        "label": {"type": {"key": {"type": "string", "minLength": 1}}},
+        "options": {"type": {"key": "string", "value": {"type": "real", "minReal": 0.5}, "min": 1, "max": "unlimited"}}
+      }
+    }
+  }
+}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	op := Operation{Op: OperationInsert, Table: "T", Row: map[string]interface{}{"name": "foo"}}
+	err := schema.ValidateTransaction([]Operation{op})
+	expected := "missing required columns [enum label options priority ref]"
+	if err == nil || !strings.HasSuffix(err.Error(), expected) {
+		t.Errorf("Expected an error ending with %q, got %v", expected, err)
+	}
+
+	op.Row = map[string]interface{}{
+		"ref":      UUID{GoUUID: "row"},
+		"enum":     "a",
+		"priority": 1,
+		"label":    "bar",
+		"options":  map[string]float64{"opt": 1},
+	}
+	if err := schema.ValidateTransaction([]Operation{op}); err != nil {
+		t.Errorf("Expected the insert to be valid, got %s", err)
+	}
+}
+
+func TestOperationValidate(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal(testSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	op := Operation{Op: OperationSelect, Table: "TestTable", Columns: []string{"aString"}}
+	if err := op.Validate(&schema); err != nil {
+		t.Errorf("Expected the operation to be valid, got %s", err)
+	}
+	op = Operation{Op: "inserts", Table: "TestTable"}
+	if err := op.Validate(&schema); err == nil || !strings.HasPrefix(err.Error(), "inserts operation") {
+		t.Errorf("Expected an error about the inserts operation, got %v", err)
+	}
+	op = Operation{Op: OperationUpdate, Table: "TestTable"}
+	if err := op.Validate(&schema); err == nil {
+		t.Error("Expected an update without a row to be invalid")
+	}
+
+	row := map[string]interface{}{"aString": "foo"}
+	where := []interface{}{}
+	mutations := []interface{}{NewMutation("aSet", MutatorInsert, "foo")}
+	for _, op := range []Operation{
+		{Op: OperationInsert, Table: "TestTable"},
+		{Op: OperationUpdate, Table: "TestTable", Row: row},
+		{Op: OperationMutate, Table: "TestTable", Mutations: mutations},
+		{Op: OperationDelete, Table: "TestTable"},
+		{Op: OperationWait, Table: "TestTable", Until: FunctionEqual},
+	} {
+		if err := op.Validate(&schema); err == nil || !strings.Contains(err.Error(), "missing") {
+			t.Errorf("Expected an error about a missing member of the %s, got %v", op.Op, err)
+		}
+	}
+	// An empty where selects all rows
+	for _, op := range []Operation{
+		{Op: OperationUpdate, Table: "TestTable", Row: row, Where: where},
+		{Op: OperationMutate, Table: "TestTable", Mutations: mutations, Where: where},
+		{Op: OperationDelete, Table: "TestTable", Where: where},
+		{Op: OperationWait, Table: "TestTable", Until: FunctionEqual, Where: where},
+	} {
+		if err := op.Validate(&schema); err != nil {
+			t.Errorf("Expected the %s to be valid, got %s", op.Op, err)
+		}
+	}
+}
+
+func TestOperationEmptyMembers(t *testing.T) {
+	for _, test := range []struct {
+		op       Operation
+		expected string
+	}{
+		{Operation{Op: OperationInsert, Table: "T", Row: map[string]interface{}{}}, `{"op":"insert","table":"T","row":{}}`},
+		{Operation{Op: OperationInsert, Table: "T"}, `{"op":"insert","table":"T"}`},
+		{Operation{Op: OperationDelete, Table: "T", Where: []interface{}{}}, `{"op":"delete","table":"T","where":[]}`},
+		{Operation{Op: OperationDelete, Table: "T"}, `{"op":"delete","table":"T"}`},
+	} {
+		b, err := json.Marshal(test.op)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got, expected interface{}
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(test.expected), &expected); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %s, got %s", test.expected, b)
+		}
+	}
+}
+
+func TestIsEphemeral(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal([]byte(`{
+  "name": "TestDB",
+  "tables": {
+    "Controller": {
+      "columns": {
+        "target": {"type": "string"},
+        "is_connected": {"type": "boolean", "ephemeral": true}
+      }
+    }
+  }
+}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+	if !schema.IsEphemeral("Controller", "is_connected") {
+		t.Error("Expected is_connected to be ephemeral")
+	}
+	for _, column := range []string{"target", "_uuid", "foo"} {
+		if schema.IsEphemeral("Controller", column) {
+			t.Errorf("Expected %s not to be ephemeral", column)
+		}
+	}
+	if schema.IsEphemeral("Foo", "target") {
+		t.Error("Expected a column of an unknown table not to be ephemeral")
+	}
+}
+
+func TestColumnMutableDefault(t *testing.T) {
+	var column ColumnSchema
+	if err := json.Unmarshal([]byte(`{"type": "string"}`), &column); err != nil {
+		t.Fatal(err)
+	}
+	if !column.Mutable {
+		t.Error("Expected columns to be mutable by default")
+	}
+	if err := json.Unmarshal([]byte(`{"type": "string", "mutable": false}`), &column); err != nil {
+		t.Fatal(err)
+	}
+	if column.Mutable {
+		t.Error("Expected column not to be mutable")
+	}
+}
+
+func TestColumnMinMax(t *testing.T) {
+	// The last max wins, so this column is an optional string
+	var column ColumnSchema
+	if err := json.Unmarshal([]byte(`{"type": {"key": "string", "min": 0, "max": "unlimited", "max": 1}}`), &column); err != nil {
+		t.Fatal(err)
+	}
+	if column.Type != TypeSet || column.TypeObj.Min != 0 || column.TypeObj.Max != 1 {
+		t.Errorf("Expected a set with min 0 and max 1, got %s", column.String())
+	}
+	if err := json.Unmarshal([]byte(`{"type": {"key": "string", "min": 0, "max": 1, "max": "unlimited"}}`), &column); err != nil {
+		t.Fatal(err)
+	}
+	if column.Type != TypeSet || column.TypeObj.Max != Unlimited {
+		t.Errorf("Expected an unlimited set, got %s", column.String())
+	}
+
+	for _, invalid := range []string{
+		`{"type": {"key": "string", "min": 2, "max": "unlimited"}}`,
+		`{"type": {"key": "string", "min": 0, "max": 0}}`,
+		`{"type": {"key": "string", "min": 1, "max": -1}}`,
+		`{"type": {"key": "string", "max": "many"}}`,
+	} {
+		var column ColumnSchema
+		if err := json.Unmarshal([]byte(invalid), &column); err == nil {
+			t.Errorf("Expected column %s to be rejected", invalid)
+		}
+	}
+}
+
+func TestSchemaCompatibleWith(t *testing.T) {
+	parse := func(s string) *DatabaseSchema {
+		var schema DatabaseSchema
+		if err := json.Unmarshal([]byte(s), &schema); err != nil {
+			t.Fatal(err)
+		}
+		return &schema
+	}
+	local := parse(`{"name": "DB", "version": "1.2.0", "cksum": "12345 678", "tables": {
+	  "A": {"columns": {"a": {"type": "string"}, "b": {"type": "integer"}}}}}`)
+	if local.Checksum != "12345 678" {
+		t.Errorf("Expected checksum to be parsed, got %q", local.Checksum)
+	}
+
+	tests := []struct {
+		name       string
+		other      string
+		compatible bool
+		diffs      []string
+	}{
+		{
+			name:       "same schema",
+			other:      `{"name": "DB", "version": "1.2.0", "tables": {"A": {"columns": {"a": {"type": "string"}, "b": {"type": "integer"}}}}}`,
+			compatible: true,
+		},
+		{
+			name: "newer minor version with additions",
+			other: `{"name": "DB", "version": "1.3.0", "tables": {
+			  "A": {"columns": {"a": {"type": "string"}, "b": {"type": "integer"}, "c": {"type": "real"}}},
+			  "B": {"columns": {"a": {"type": "string"}}}}}`,
+			compatible: true,
+			diffs:      []string{"version: 1.2.0 != 1.3.0", "column A.c: added", "table B: added"},
+		},
+		{
+			name:       "newer major version",
+			other:      `{"name": "DB", "version": "2.0.0", "tables": {"A": {"columns": {"a": {"type": "string"}, "b": {"type": "integer"}}}}}`,
+			compatible: false,
+			diffs:      []string{"version: 1.2.0 != 2.0.0"},
+		},
+		{
+			name:       "missing and changed columns",
+			other:      `{"name": "DB", "version": "1.2.0", "tables": {"A": {"columns": {"b": {"type": "string"}}}}}`,
+			compatible: false,
+			diffs:      []string{"column A.a: missing", "column A.b: type integer [M] != string [M]"},
+		},
+		{
+			name:       "missing table",
+			other:      `{"name": "DB", "version": "1.2.0", "tables": {}}`,
+			compatible: false,
+			diffs:      []string{"table A: missing"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			compatible, diffs := local.CompatibleWith(parse(test.other))
+			if compatible != test.compatible {
+				t.Errorf("Expected compatible to be %t, got %t (%v)", test.compatible, compatible, diffs)
+			}
+			if !reflect.DeepEqual(diffs, test.diffs) {
+				t.Errorf("Expected differences %q, got %q", test.diffs, diffs)
+			}
+		})
+	}
+}
+
+func TestSchemaPrintDotAndJSON(t *testing.T) {
+	schemaJSON := []byte(`{
+  "name": "TestDB",
+  "version": "1.0.0",
+  "tables": {
+    "Bridge": {
+      "columns": {
+        "name": {"type": "string"},
+        "ports": {"type": {"key": {"type": "uuid", "refTable": "Port"}, "min": 0, "max": "unlimited"}},
+        "mirrors": {"type": {"key": "string", "value": {"type": "uuid", "refTable": "Mirror", "refType": "weak"}, "min": 0, "max": "unlimited"}}
+      },
+      "indexes": [["name"]]
+    },
+    "Port": {"columns": {"name": {"type": "string"}}},
+    "Mirror": {"columns": {"name": {"type": "string", "mutable": false}}}
+  }
+}`)
+	var schema DatabaseSchema
+	if err := json.Unmarshal(schemaJSON, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	var dot strings.Builder
+	schema.PrintDot(&dot)
+	expected := `digraph "TestDB" {
+	"Bridge";
+	"Mirror";
+	"Port";
+	"Bridge" -> "Mirror" [label="mirrors", style=dashed];
+	"Bridge" -> "Port" [label="ports", style=solid];
+}
+`
+	if dot.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, dot.String())
+	}
+
+	var buf strings.Builder
+	if err := schema.PrintJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var parsed DatabaseSchema
+	if err := json.Unmarshal([]byte(buf.String()), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(schema, parsed) {
+		t.Errorf("Expected the printed JSON schema to parse back to %+v, got %+v", schema, parsed)
+	}
+}
+
+func TestTableIndexes(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal([]byte(`{
+  "name": "TestDB",
+  "tables": {
+    "Bridge": {
+      "columns": {"name": {"type": "string"}, "datapath": {"type": "string"}, "other": {"type": "string"}},
+      "indexes": [["name"], ["name", "datapath"]]
+    },
+    "Port": {"columns": {"name": {"type": "string"}}}
+  }
+}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	indexes, err := schema.TableIndexes("Bridge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{{"_uuid"}, {"name"}, {"name", "datapath"}}
+	if !reflect.DeepEqual(indexes, expected) {
+		t.Errorf("Expected %v, got %v", expected, indexes)
+	}
+	indexes, err = schema.TableIndexes("Port")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(indexes, [][]string{{"_uuid"}}) {
+		t.Errorf("Expected only the _uuid index, got %v", indexes)
+	}
+	if _, err := schema.TableIndexes("Foo"); err == nil {
+		t.Error("Expected an error for an unknown table")
+	}
+
+	bridge := schema.Tables["Bridge"]
+	for _, columns := range [][]string{{"_uuid"}, {"name"}, {"name", "datapath"}, {"datapath", "name"}} {
+		if !bridge.HasIndex(columns...) {
+			t.Errorf("Expected an index on %v", columns)
+		}
+	}
+	for _, columns := range [][]string{{}, {"datapath"}, {"other"}, {"name", "other"}, {"name", "datapath", "other"}} {
+		if bridge.HasIndex(columns...) {
+			t.Errorf("Expected no index on %v", columns)
+		}
+	}
+}
+
+func TestHasTableColumns(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal([]byte(`{
+  "name": "TestDB",
+  "tables": {
+    "Bridge": {"columns": {"name": {"type": "string"}, "datapath": {"type": "string"}, "other": {"type": "string"}}}
+  }
+}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	if !schema.HasTable("Bridge") {
+		t.Error("Expected table Bridge to exist")
+	}
+	if schema.HasTable("Foo") {
+		t.Error("Expected table Foo not to exist")
+	}
+	columns, err := schema.Columns("Bridge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"datapath", "name", "other"}
+	if !reflect.DeepEqual(columns, expected) {
+		t.Errorf("Expected %v, got %v", expected, columns)
+	}
+	if _, err := schema.Columns("Foo"); err == nil {
+		t.Error("Expected an error for an unknown table")
+	}
+}
+
+func TestValidateTransactionNamedUUIDs(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal([]byte(`{"name": "TestDB", "tables": {"Port": {"columns": {"name": {"type": "string"}}}}}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+	insert := func(name string) Operation {
+		return Operation{Op: OperationInsert, Table: "Port", Row: map[string]interface{}{}, UUIDName: name}
+	}
+
+	if err := schema.ValidateTransaction([]Operation{insert("p0"), insert("p1")}); err != nil {
+		t.Errorf("Expected the transaction to be valid, got %s", err)
+	}
+	err := schema.ValidateTransaction([]Operation{insert("p0"), insert("p0")})
+	if err == nil || !strings.Contains(err.Error(), "already used by operation 0") {
+		t.Errorf("Expected an error about the duplicated named-uuid, got %v", err)
+	}
+	for _, name := range []string{"port-0", "0port", "2f77b348-9768-4866-b761-89d5177ecda0"} {
+		if err := schema.ValidateTransaction([]Operation{insert(name)}); err == nil {
+			t.Errorf("Expected named-uuid %q to be rejected", name)
+		}
+	}
+}
+
+func TestDiffRows(t *testing.T) {
+	var schema DatabaseSchema
+	if err := json.Unmarshal(testSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	old := GetOvsRow()
+	updated := Row{Fields: make(map[string]interface{}, len(old.Fields))}
+	for column, value := range old.Fields {
+		updated.Fields[column] = value
+	}
+	// The same set in another order is not a change
+	reversed := make([]string, 0, len(aSet))
+	for i := len(aSet) - 1; i >= 0; i-- {
+		reversed = append(reversed, aSet[i])
+	}
+	set, err := NewOvsSet(reversed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated.Fields["aSet"] = *set
+	updated.Fields["aString"] = "bar"
+	delete(updated.Fields, "aMap")
+	updated.Fields["_version"] = UUID{GoUUID: aUUID1}
+
+	changes, err := schema.DiffRows("TestTable", old, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]ColumnChange{
+		"aString": {Old: aString, New: "bar"},
+		"aMap":    {Old: aMap, New: nil},
+	}
+	if !reflect.DeepEqual(expected, changes) {
+		t.Errorf("Expected %v, got %v", expected, changes)
+	}
+
+	changes, err = schema.DiffRows("TestTable", old, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Expected no change, got %v", changes)
+	}
+
+	if _, err := schema.DiffRows("NoSuchTable", old, updated); err == nil {
+		t.Error("Expected an error for an unknown table")
+	}
+	updated.Fields["aString"] = 42
+	if _, err := schema.DiffRows("TestTable", old, updated); err == nil {
+		t.Error("Expected an error for an invalid value")
+	}
+}
@@ -3,6 +3,7 @@ package libovsdb
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"reflect"
 )
 
@@ -17,14 +18,48 @@ type OvsSet struct {
 	GoSet []interface{}
 }
 
+// isSetElemType returns whether t can hold an atomic type (UUID included)
+func isSetElemType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Bool:
+		return true
+	}
+	return t == reflect.TypeOf(UUID{})
+}
+
 // NewOvsSet creates a new OVSDB style set from a Go interface (object)
+// Since the server rejects sets with duplicate elements, duplicates are dropped,
+// keeping the elements in the order they are first seen
 func NewOvsSet(obj interface{}) (*OvsSet, error) {
 	v := reflect.ValueOf(obj)
 	var ovsSet []interface{}
 	switch v.Kind() {
 	case reflect.Slice, reflect.Array:
+		var elemType reflect.Type
+		// The atomic types are comparable, so the elements can be map keys
+		seen := make(map[interface{}]struct{}, v.Len())
 		for i := 0; i < v.Len(); i++ {
-			ovsSet = append(ovsSet, v.Index(i).Interface())
+			elem := v.Index(i)
+			if elem.Kind() == reflect.Interface {
+				elem = elem.Elem()
+			}
+			if !elem.IsValid() || !isSetElemType(elem.Type()) {
+				return nil, fmt.Errorf("OvsSet does not support element %v", v.Index(i))
+			}
+			// OVSDB sets are homogeneous
+			if elemType == nil {
+				elemType = elem.Type()
+			} else if elem.Type() != elemType {
+				return nil, fmt.Errorf("OvsSet elements must have the same type: got %v and %v", elemType, elem.Type())
+			}
+			if _, ok := seen[elem.Interface()]; ok {
+				continue
+			}
+			seen[elem.Interface()] = struct{}{}
+			ovsSet = append(ovsSet, elem.Interface())
 		}
 	case reflect.String,
 		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
@@ -64,7 +99,7 @@ func (o *OvsSet) UnmarshalJSON(b []byte) (err error) {
 	}
 
 	var inter interface{}
-	if err = json.Unmarshal(b, &inter); err != nil {
+	if err = unmarshalUseNumber(b, &inter); err != nil {
 		return err
 	}
 	switch inter.(type) {
@@ -92,3 +127,75 @@ func (o *OvsSet) UnmarshalJSON(b []byte) (err error) {
 		return addToSet(o, inter)
 	}
 }
+
+// Add inserts elem into the set. Since OVSDB sets are homogeneous, elem must be
+// of the same type as the elements already present in the set
+// Adding an element that is already present is a no-op
+func (o *OvsSet) Add(elem interface{}) error {
+	if len(o.GoSet) > 0 {
+		expected := reflect.TypeOf(o.GoSet[0])
+		if reflect.TypeOf(elem) != expected {
+			return NewErrWrongType("OvsSet.Add", expected.String(), elem)
+		}
+	}
+	if o.Contains(elem) {
+		return nil
+	}
+	o.GoSet = append(o.GoSet, elem)
+	return nil
+}
+
+// Remove deletes elem from the set, if present
+func (o *OvsSet) Remove(elem interface{}) {
+	for i, e := range o.GoSet {
+		if reflect.DeepEqual(e, elem) {
+			o.GoSet = append(o.GoSet[:i], o.GoSet[i+1:]...)
+			return
+		}
+	}
+}
+
+// Contains returns whether elem is a member of the set
+func (o *OvsSet) Contains(elem interface{}) bool {
+	for _, e := range o.GoSet {
+		if reflect.DeepEqual(e, elem) {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of elements of the set. A nil set is empty
+func (o *OvsSet) Len() int {
+	if o == nil {
+		return 0
+	}
+	return len(o.GoSet)
+}
+
+// IsEmpty returns whether the set has no element
+func (o *OvsSet) IsEmpty() bool {
+	return o.Len() == 0
+}
+
+// Equal returns whether both sets have the same elements, in any order. A nil
+// set is equal to an empty one
+func (o *OvsSet) Equal(other *OvsSet) bool {
+	if o.Len() != other.Len() {
+		return false
+	}
+	if o.IsEmpty() {
+		return true
+	}
+	for _, e := range o.GoSet {
+		if !other.Contains(e) {
+			return false
+		}
+	}
+	for _, e := range other.GoSet {
+		if !o.Contains(e) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,526 @@
+// Package testovsdb provides an in-memory OVSDB server that speaks enough of
+// the RFC7047 JSON-RPC protocol to exercise code that uses libovsdb without
+// a real ovsdb-server.
+//
+// Supported methods are list_dbs, get_schema, transact (insert, select,
+// update, delete, comment and abort operations), monitor, monitor_cancel and
+// echo.
+// Conditions support the "==" and "!=" functions.
+package testovsdb
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+
+	"github.com/cenkalti/rpc2"
+	"github.com/cenkalti/rpc2/jsonrpc"
+	"github.com/ebay/libovsdb"
+)
+
+// row is a table row in its json decoded wire format
+type row map[string]interface{}
+
+// Server is an in-memory OVSDB server holding a single database
+type Server struct {
+	schema   *libovsdb.DatabaseSchema
+	tables   map[string]map[string]row
+	monitors map[*rpc2.Client][]*monitor
+	mutex    sync.Mutex
+}
+
+// monitor is an active monitor registered by a client
+type monitor struct {
+	jsonValue interface{}
+	requests  map[string]libovsdb.MonitorRequest
+}
+
+// rowEvent describes a change to a row done by a transaction
+type rowEvent struct {
+	table string
+	uuid  string
+	old   row
+	new   row
+}
+
+// NewServer returns a Server for the provided schema with an empty database
+func NewServer(schema *libovsdb.DatabaseSchema) *Server {
+	tables := make(map[string]map[string]row, len(schema.Tables))
+	for name := range schema.Tables {
+		tables[name] = make(map[string]row)
+	}
+	return &Server{
+		schema:   schema,
+		tables:   tables,
+		monitors: make(map[*rpc2.Client][]*monitor),
+	}
+}
+
+// NewTestClient returns an OvsdbClient connected to a new Server for the
+// provided schema through an in-process pipe
+func NewTestClient(schema *libovsdb.DatabaseSchema) (*libovsdb.OvsdbClient, error) {
+	return NewServer(schema).NewClient()
+}
+
+// NewClient returns an OvsdbClient connected to the server through an
+// in-process pipe
+func (s *Server) NewClient() (*libovsdb.OvsdbClient, error) {
+	clientConn, serverConn := net.Pipe()
+	s.Serve(serverConn)
+	return libovsdb.ConnectWithConn(clientConn, "", nil)
+}
+
+// Serve starts serving the JSON-RPC protocol over conn. It does not block
+func (s *Server) Serve(conn net.Conn) {
+	c := rpc2.NewClientWithCodec(jsonrpc.NewJSONCodec(conn))
+	c.SetBlocking(true)
+	c.Handle("list_dbs", s.listDbs)
+	c.Handle("get_schema", s.getSchema)
+	c.Handle("transact", s.transact)
+	c.Handle("monitor", s.monitor)
+	c.Handle("monitor_cancel", s.monitorCancel)
+	c.Handle("echo", echo)
+	go c.Run()
+	go func() {
+		<-c.DisconnectNotify()
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		delete(s.monitors, c)
+	}()
+}
+
+func (s *Server) listDbs(_ *rpc2.Client, _ []interface{}, reply *[]string) error {
+	*reply = []string{s.schema.Name}
+	return nil
+}
+
+func (s *Server) getSchema(_ *rpc2.Client, args []interface{}, reply *libovsdb.DatabaseSchema) error {
+	if len(args) != 1 || args[0] != s.schema.Name {
+		return fmt.Errorf("unknown database %v", args)
+	}
+	*reply = *s.schema
+	return nil
+}
+
+func echo(_ *rpc2.Client, args []interface{}, reply *[]interface{}) error {
+	*reply = args
+	return nil
+}
+
+// transact runs the operations on a copy of the database which replaces the
+// current one only if every operation succeeds
+func (s *Server) transact(_ *rpc2.Client, args []interface{}, reply *[]interface{}) error {
+	if len(args) < 1 || args[0] != s.schema.Name {
+		return fmt.Errorf("unknown database %v", args)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tables := make(map[string]map[string]row, len(s.tables))
+	for name, rows := range s.tables {
+		tables[name] = make(map[string]row, len(rows))
+		for uuid, r := range rows {
+			tables[name][uuid] = r
+		}
+	}
+
+	// Named uuids can be referenced by any operation of the transaction
+	namedUUIDs := make(map[string]string)
+	for _, arg := range args[1:] {
+		if op, ok := arg.(map[string]interface{}); ok {
+			if name, ok := op["uuid-name"].(string); ok {
+				namedUUIDs[name] = newUUID()
+			}
+		}
+	}
+
+	var events []rowEvent
+	results := make([]interface{}, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		op, ok := resolveNamedUUIDs(arg, namedUUIDs).(map[string]interface{})
+		if !ok {
+			results = append(results, opError("syntax error", "operation is not an object"))
+			*reply = results
+			return nil
+		}
+		result, opEvents, err := s.runOperation(tables, op, namedUUIDs)
+		if err == errAborted {
+			results = append(results, opError("aborted", "aborted by request"))
+			*reply = results
+			return nil
+		}
+		if err != nil {
+			results = append(results, opError("constraint violation", err.Error()))
+			*reply = results
+			return nil
+		}
+		results = append(results, result)
+		events = append(events, opEvents...)
+	}
+	s.tables = tables
+	s.notify(events)
+	*reply = results
+	return nil
+}
+
+// errAborted is returned by runOperation for the abort operation
+var errAborted = errors.New("aborted")
+
+func opError(err, details string) map[string]interface{} {
+	return map[string]interface{}{
+		"error":   err,
+		"details": details,
+	}
+}
+
+func (s *Server) runOperation(tables map[string]map[string]row, op map[string]interface{}, namedUUIDs map[string]string) (map[string]interface{}, []rowEvent, error) {
+	switch op["op"] {
+	case libovsdb.OperationComment:
+		return map[string]interface{}{}, nil, nil
+	case libovsdb.OperationAbort:
+		return nil, nil, errAborted
+	}
+	table, _ := op["table"].(string)
+	rows, ok := tables[table]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown table %q", table)
+	}
+	where, _ := op["where"].([]interface{})
+
+	switch op["op"] {
+	case libovsdb.OperationInsert:
+		uuid := newUUID()
+		if name, ok := op["uuid-name"].(string); ok {
+			uuid = namedUUIDs[name]
+		}
+		newRow := row{}
+		if values, ok := op["row"].(map[string]interface{}); ok {
+			for column, value := range values {
+				newRow[column] = value
+			}
+		}
+		newRow["_uuid"] = []interface{}{"uuid", uuid}
+		newRow["_version"] = []interface{}{"uuid", newUUID()}
+		rows[uuid] = newRow
+		return map[string]interface{}{
+			"uuid": []interface{}{"uuid", uuid},
+		}, []rowEvent{{table: table, uuid: uuid, new: newRow}}, nil
+
+	case libovsdb.OperationSelect:
+		columns, _ := op["columns"].([]interface{})
+		matching := []interface{}{}
+		for _, r := range rows {
+			match, err := matches(r, where)
+			if err != nil {
+				return nil, nil, err
+			}
+			if match {
+				matching = append(matching, filterColumns(r, columns))
+			}
+		}
+		return map[string]interface{}{"rows": matching}, nil, nil
+
+	case libovsdb.OperationUpdate:
+		values, _ := op["row"].(map[string]interface{})
+		var events []rowEvent
+		for uuid, r := range rows {
+			match, err := matches(r, where)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !match {
+				continue
+			}
+			newRow := make(row, len(r))
+			for column, value := range r {
+				newRow[column] = value
+			}
+			for column, value := range values {
+				newRow[column] = value
+			}
+			newRow["_version"] = []interface{}{"uuid", newUUID()}
+			rows[uuid] = newRow
+			events = append(events, rowEvent{table: table, uuid: uuid, old: r, new: newRow})
+		}
+		return map[string]interface{}{"count": len(events)}, events, nil
+
+	case libovsdb.OperationDelete:
+		var events []rowEvent
+		for uuid, r := range rows {
+			match, err := matches(r, where)
+			if err != nil {
+				return nil, nil, err
+			}
+			if match {
+				delete(rows, uuid)
+				events = append(events, rowEvent{table: table, uuid: uuid, old: r})
+			}
+		}
+		return map[string]interface{}{"count": len(events)}, events, nil
+
+	default:
+		return nil, nil, fmt.Errorf("operation %v not supported", op["op"])
+	}
+}
+
+// matches returns whether the row satisfies every condition
+func matches(r row, where []interface{}) (bool, error) {
+	for _, c := range where {
+		condition, ok := c.([]interface{})
+		if !ok || len(condition) != 3 {
+			return false, fmt.Errorf("invalid condition %v", c)
+		}
+		column, _ := condition[0].(string)
+		equal := reflect.DeepEqual(r[column], condition[2])
+		switch condition[1] {
+		case libovsdb.FunctionEqual:
+			if !equal {
+				return false, nil
+			}
+		case libovsdb.FunctionNotEqual:
+			if equal {
+				return false, nil
+			}
+		default:
+			return false, fmt.Errorf("function %v not supported", condition[1])
+		}
+	}
+	return true, nil
+}
+
+// filterColumns returns a row containing only the provided columns, or the
+// whole row if no column is provided
+func filterColumns(r row, columns []interface{}) row {
+	if len(columns) == 0 {
+		return r
+	}
+	filtered := make(row, len(columns))
+	for _, c := range columns {
+		if column, ok := c.(string); ok {
+			if value, ok := r[column]; ok {
+				filtered[column] = value
+			}
+		}
+	}
+	return filtered
+}
+
+// resolveNamedUUIDs replaces every ["named-uuid", <name>] in value by the
+// ["uuid", <uuid>] it refers to
+func resolveNamedUUIDs(value interface{}, namedUUIDs map[string]string) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		if len(v) == 2 && v[0] == "named-uuid" {
+			if name, ok := v[1].(string); ok {
+				if uuid, ok := namedUUIDs[name]; ok {
+					return []interface{}{"uuid", uuid}
+				}
+			}
+		}
+		resolved := make([]interface{}, len(v))
+		for i, elem := range v {
+			resolved[i] = resolveNamedUUIDs(elem, namedUUIDs)
+		}
+		return resolved
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			resolved[key] = resolveNamedUUIDs(elem, namedUUIDs)
+		}
+		return resolved
+	default:
+		return value
+	}
+}
+
+func (s *Server) monitor(client *rpc2.Client, args []interface{}, reply *map[string]map[string]interface{}) error {
+	if len(args) != 3 || args[0] != s.schema.Name {
+		return fmt.Errorf("invalid monitor request %v", args)
+	}
+	requests, err := parseMonitorRequests(args[2])
+	if err != nil {
+		return err
+	}
+	for table := range requests {
+		if _, ok := s.schema.Tables[table]; !ok {
+			return fmt.Errorf("unknown table %q", table)
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, m := range s.monitors[client] {
+		if reflect.DeepEqual(m.jsonValue, args[1]) {
+			return errors.New("duplicate monitor ID")
+		}
+	}
+	m := &monitor{
+		jsonValue: args[1],
+		requests:  requests,
+	}
+	s.monitors[client] = append(s.monitors[client], m)
+
+	initial := make(map[string]map[string]interface{})
+	for table, request := range requests {
+		if !request.Select.Initial {
+			continue
+		}
+		for uuid, r := range s.tables[table] {
+			if initial[table] == nil {
+				initial[table] = make(map[string]interface{})
+			}
+			initial[table][uuid] = map[string]interface{}{
+				"new": monitoredColumns(r, request.Columns),
+			}
+		}
+	}
+	*reply = initial
+	return nil
+}
+
+// parseMonitorRequests decodes the <monitor-requests> object. Every select
+// flag defaults to true as per RFC7047
+func parseMonitorRequests(raw interface{}) (map[string]libovsdb.MonitorRequest, error) {
+	rawRequests, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid monitor requests %v", raw)
+	}
+	requests := make(map[string]libovsdb.MonitorRequest, len(rawRequests))
+	for table, rawRequest := range rawRequests {
+		// A table can have a single request or an array of them. Only the
+		// first one is taken into account
+		if list, ok := rawRequest.([]interface{}); ok && len(list) > 0 {
+			rawRequest = list[0]
+		}
+		fields, _ := rawRequest.(map[string]interface{})
+		request := libovsdb.MonitorRequest{
+			Select: libovsdb.MonitorSelect{
+				Initial: true,
+				Insert:  true,
+				Delete:  true,
+				Modify:  true,
+			},
+		}
+		if columns, ok := fields["columns"].([]interface{}); ok {
+			for _, c := range columns {
+				if column, ok := c.(string); ok {
+					request.Columns = append(request.Columns, column)
+				}
+			}
+		}
+		if flags, ok := fields["select"].(map[string]interface{}); ok {
+			for flag, value := range flags {
+				enabled, _ := value.(bool)
+				switch flag {
+				case "initial":
+					request.Select.Initial = enabled
+				case "insert":
+					request.Select.Insert = enabled
+				case "delete":
+					request.Select.Delete = enabled
+				case "modify":
+					request.Select.Modify = enabled
+				}
+			}
+		}
+		requests[table] = request
+	}
+	return requests, nil
+}
+
+// monitoredColumns returns the row restricted to the monitored columns.
+// _uuid is never included in monitor updates
+func monitoredColumns(r row, columns []string) row {
+	filtered := make(row, len(r))
+	for column, value := range r {
+		if column == "_uuid" {
+			continue
+		}
+		if len(columns) > 0 && !contains(columns, column) {
+			continue
+		}
+		filtered[column] = value
+	}
+	return filtered
+}
+
+func contains(list []string, elem string) bool {
+	for _, e := range list {
+		if e == elem {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) monitorCancel(client *rpc2.Client, args []interface{}, reply *map[string]interface{}) error {
+	if len(args) != 1 {
+		return fmt.Errorf("invalid monitor_cancel request %v", args)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i, m := range s.monitors[client] {
+		if reflect.DeepEqual(m.jsonValue, args[0]) {
+			s.monitors[client] = append(s.monitors[client][:i], s.monitors[client][i+1:]...)
+			*reply = map[string]interface{}{}
+			return nil
+		}
+	}
+	return errors.New("unknown monitor")
+}
+
+// notify sends an update notification to every monitor interested in the
+// events. It must be called with the server mutex held
+func (s *Server) notify(events []rowEvent) {
+	for client, monitors := range s.monitors {
+		for _, m := range monitors {
+			updates := make(map[string]map[string]interface{})
+			for _, event := range events {
+				request, ok := m.requests[event.table]
+				if !ok {
+					continue
+				}
+				rowUpdate := make(map[string]interface{})
+				switch {
+				case event.old == nil && request.Select.Insert:
+					rowUpdate["new"] = monitoredColumns(event.new, request.Columns)
+				case event.new == nil && request.Select.Delete:
+					rowUpdate["old"] = monitoredColumns(event.old, request.Columns)
+				case event.old != nil && event.new != nil && request.Select.Modify:
+					// old only holds the columns that changed
+					changed := row{}
+					for column, value := range monitoredColumns(event.old, request.Columns) {
+						if !reflect.DeepEqual(value, event.new[column]) {
+							changed[column] = value
+						}
+					}
+					rowUpdate["old"] = changed
+					rowUpdate["new"] = monitoredColumns(event.new, request.Columns)
+				default:
+					continue
+				}
+				if updates[event.table] == nil {
+					updates[event.table] = make(map[string]interface{})
+				}
+				updates[event.table][event.uuid] = rowUpdate
+			}
+			if len(updates) > 0 {
+				client.Notify("update", []interface{}{m.jsonValue, updates})
+			}
+		}
+	}
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
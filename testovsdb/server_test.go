@@ -0,0 +1,284 @@
+package testovsdb
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ebay/libovsdb"
+	"github.com/stretchr/testify/assert"
+)
+
+var testSchema = []byte(`{
+  "name": "TestDB",
+  "version": "0.0.1",
+  "tables": {
+    "Bridge": {
+      "columns": {
+        "name": {
+          "type": "string"
+        },
+        "ports": {
+          "type": {
+            "key": {
+              "type": "uuid",
+              "refTable": "Port"
+            },
+            "min": 0,
+            "max": "unlimited"
+          }
+        },
+        "external_ids": {
+          "type": {
+            "key": "string",
+            "value": "string",
+            "min": 0,
+            "max": "unlimited"
+          }
+        }
+      }
+    },
+    "Port": {
+      "columns": {
+        "name": {
+          "type": "string"
+        }
+      }
+    }
+  }
+}`)
+
+type notifier struct {
+	updates chan libovsdb.TableUpdates
+}
+
+func (n *notifier) Update(context interface{}, tableUpdates libovsdb.TableUpdates) {
+	n.updates <- tableUpdates
+}
+func (n *notifier) Locked([]interface{}) {
+}
+func (n *notifier) Stolen([]interface{}) {
+}
+func (n *notifier) Echo([]interface{}) {
+}
+func (n *notifier) Disconnected(*libovsdb.OvsdbClient) {
+}
+
+func newTestClient(t *testing.T) *libovsdb.OvsdbClient {
+	var schema libovsdb.DatabaseSchema
+	if err := json.Unmarshal(testSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	ovs, err := NewTestClient(&schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ovs
+}
+
+func TestSchema(t *testing.T) {
+	ovs := newTestClient(t)
+	defer ovs.Disconnect()
+	dbs, err := ovs.ListDbs()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"TestDB"}, dbs)
+	schema, ok := ovs.SchemaFor("TestDB")
+	assert.True(t, ok)
+	assert.Len(t, schema.Tables, 2)
+}
+
+func TestTransact(t *testing.T) {
+	ovs := newTestClient(t)
+	defer ovs.Disconnect()
+	api, _ := ovs.APIFor("TestDB")
+
+	port, err := api.NewRow("Port", map[string]interface{}{"name": "port0"})
+	assert.Nil(t, err)
+	bridge, err := api.NewRow("Bridge", map[string]interface{}{
+		"name":         "br0",
+		"external_ids": map[string]string{"foo": "bar"},
+	})
+	assert.Nil(t, err)
+	bridge["ports"] = libovsdb.UUID{GoUUID: "port"}
+
+	results, err := ovs.Transact("TestDB",
+		libovsdb.Operation{Op: "insert", Table: "Port", Row: port, UUIDName: "port"},
+		libovsdb.Operation{Op: "insert", Table: "Bridge", Row: bridge})
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+	portUUID := results[0].UUID.GoUUID
+	bridgeUUID := results[1].UUID.GoUUID
+
+	condition, err := api.NewCondition("Bridge", "name", "==", "br0")
+	assert.Nil(t, err)
+	results, err = ovs.Transact("TestDB", libovsdb.Operation{
+		Op:    "select",
+		Table: "Bridge",
+		Where: []interface{}{condition},
+	})
+	assert.Nil(t, err)
+	assert.Len(t, results[0].Rows, 1)
+	data, err := api.GetData("Bridge", results[0].Rows[0])
+	assert.Nil(t, err)
+	assert.Equal(t, "br0", data["name"])
+	assert.Equal(t, []string{portUUID}, data["ports"])
+	assert.Equal(t, map[string]string{"foo": "bar"}, data["external_ids"])
+	assert.Equal(t, libovsdb.UUID{GoUUID: bridgeUUID}, results[0].Rows[0]["_uuid"])
+
+	results, err = ovs.Transact("TestDB", libovsdb.Operation{
+		Op:    "update",
+		Table: "Bridge",
+		Row:   map[string]interface{}{"name": "br1"},
+		Where: []interface{}{[]interface{}{"name", "==", "no such bridge"}},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, results[0].RowCount())
+
+	results, err = ovs.Transact("TestDB", libovsdb.Operation{
+		Op:    "delete",
+		Table: "Bridge",
+		Where: []interface{}{condition},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, results[0].RowCount())
+
+	results, err = ovs.Transact("TestDB", libovsdb.Operation{
+		Op:    "select",
+		Table: "Bridge",
+	})
+	assert.Nil(t, err)
+	assert.Len(t, results[0].Rows, 0)
+}
+
+func TestSelect(t *testing.T) {
+	ovs := newTestClient(t)
+	defer ovs.Disconnect()
+	api, _ := ovs.APIFor("TestDB")
+
+	_, err := ovs.Transact("TestDB",
+		libovsdb.Operation{Op: "insert", Table: "Bridge", Row: map[string]interface{}{"name": "br0"}},
+		libovsdb.Operation{Op: "insert", Table: "Bridge", Row: map[string]interface{}{"name": "br1"}})
+	assert.Nil(t, err)
+
+	rows, err := ovs.Select("TestDB", "Bridge", nil)
+	assert.Nil(t, err)
+	assert.Len(t, rows, 2)
+
+	condition, err := api.NewCondition("Bridge", "name", "==", "br0")
+	assert.Nil(t, err)
+	rows, err = ovs.Select("TestDB", "Bridge", []string{"name"}, condition)
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]interface{}{{"name": "br0"}}, rows)
+
+	condition, err = api.NewCondition("Bridge", "name", "==", "no such bridge")
+	assert.Nil(t, err)
+	rows, err = ovs.Select("TestDB", "Bridge", nil, condition)
+	assert.Nil(t, err)
+	assert.NotNil(t, rows)
+	assert.Len(t, rows, 0)
+
+	_, err = ovs.Select("NoSuchDB", "Bridge", nil)
+	assert.NotNil(t, err)
+}
+
+func TestTransactFailureIsAtomic(t *testing.T) {
+	ovs := newTestClient(t)
+	defer ovs.Disconnect()
+
+	results, err := ovs.Transact("TestDB",
+		libovsdb.Operation{Op: "insert", Table: "Port", Row: map[string]interface{}{"name": "port0"}},
+		libovsdb.Operation{Op: "wait", Table: "Port"})
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+	assert.NotEmpty(t, results[1].Error)
+
+	results, err = ovs.Transact("TestDB", libovsdb.Operation{Op: "select", Table: "Port"})
+	assert.Nil(t, err)
+	assert.Len(t, results[0].Rows, 0)
+}
+
+func TestTransactAbort(t *testing.T) {
+	ovs := newTestClient(t)
+	defer ovs.Disconnect()
+
+	results, err := ovs.Transact("TestDB",
+		libovsdb.Operation{Op: "insert", Table: "Port", Row: map[string]interface{}{"name": "port0"}},
+		libovsdb.NewAbortOperation())
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, "aborted", results[1].Error)
+
+	results, err = ovs.Transact("TestDB", libovsdb.Operation{Op: "select", Table: "Port"})
+	assert.Nil(t, err)
+	assert.Len(t, results[0].Rows, 0)
+}
+
+func TestMonitor(t *testing.T) {
+	ovs := newTestClient(t)
+	defer ovs.Disconnect()
+	n := &notifier{updates: make(chan libovsdb.TableUpdates, 10)}
+	ovs.Register(n)
+
+	_, err := ovs.Transact("TestDB",
+		libovsdb.Operation{Op: "insert", Table: "Port", Row: map[string]interface{}{"name": "port0"}})
+	assert.Nil(t, err)
+
+	initial, err := ovs.MonitorAll("TestDB", "monitor")
+	assert.Nil(t, err)
+	assert.Len(t, initial.Updates["Port"].Rows, 1)
+
+	results, err := ovs.Transact("TestDB",
+		libovsdb.Operation{Op: "insert", Table: "Bridge", Row: map[string]interface{}{"name": "br0"}})
+	assert.Nil(t, err)
+	select {
+	case u := <-n.updates:
+		rowUpdate, ok := u.Updates["Bridge"].Rows[results[0].UUID.GoUUID]
+		assert.True(t, ok)
+		assert.Equal(t, "br0", rowUpdate.New.Fields["name"])
+	case <-time.After(time.Second):
+		t.Fatal("update not received")
+	}
+
+	assert.Nil(t, ovs.MonitorCancel("monitor"))
+	_, err = ovs.Transact("TestDB",
+		libovsdb.Operation{Op: "insert", Table: "Bridge", Row: map[string]interface{}{"name": "br1"}})
+	assert.Nil(t, err)
+	select {
+	case <-n.updates:
+		t.Fatal("update received after monitor_cancel")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMonitorSelect(t *testing.T) {
+	ovs := newTestClient(t)
+	defer ovs.Disconnect()
+	n := &notifier{updates: make(chan libovsdb.TableUpdates, 10)}
+	ovs.Register(n)
+
+	_, err := ovs.Monitor("TestDB", "monitor", map[string]libovsdb.MonitorRequest{
+		"Bridge": {Select: libovsdb.MonitorSelect{Insert: true, Delete: true}},
+	})
+	assert.Nil(t, err)
+
+	_, err = ovs.Transact("TestDB",
+		libovsdb.Operation{Op: "insert", Table: "Bridge", Row: map[string]interface{}{"name": "br0"}})
+	assert.Nil(t, err)
+	select {
+	case <-n.updates:
+	case <-time.After(time.Second):
+		t.Fatal("insert update not received")
+	}
+
+	_, err = ovs.Transact("TestDB", libovsdb.Operation{
+		Op:    "update",
+		Table: "Bridge",
+		Row:   map[string]interface{}{"name": "br1"},
+	})
+	assert.Nil(t, err)
+	select {
+	case <-n.updates:
+		t.Fatal("modify update received while not selected")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
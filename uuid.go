@@ -3,10 +3,15 @@ package libovsdb
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"regexp"
 )
 
 // UUID is a UUID according to RFC7047
+// GoUUID holds either a real UUID or the name given with the uuid-name of an
+// insert to the row it creates. The two are told apart by their format, so the
+// same type can be used wherever a UUID is expected (e.g: in rows, conditions,
+// mutations or sets) and is encoded as ["uuid", <uuid>] or ["named-uuid", <id>]
 type UUID struct {
 	GoUUID string `json:"uuid"`
 }
@@ -27,22 +32,51 @@ func (u UUID) MarshalJSON() ([]byte, error) {
 // UnmarshalJSON will unmarshal a JSON encoded byte array to a OVSDB style UUID
 func (u *UUID) UnmarshalJSON(b []byte) (err error) {
 	var ovsUUID []string
-	if err := json.Unmarshal(b, &ovsUUID); err == nil {
-		u.GoUUID = ovsUUID[1]
+	if err := json.Unmarshal(b, &ovsUUID); err != nil {
+		return err
 	}
-	return err
+	if len(ovsUUID) != 2 || (ovsUUID[0] != "uuid" && ovsUUID[0] != "named-uuid") {
+		return fmt.Errorf("invalid OVSDB UUID %s", b)
+	}
+	u.GoUUID = ovsUUID[1]
+	return nil
 }
 
+var (
+	validUUID      = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	validNamedUUID = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
 func (u UUID) validateUUID() error {
 	if len(u.GoUUID) != 36 {
 		return errors.New("uuid exceeds 36 characters")
 	}
 
-	var validUUID = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
-
 	if !validUUID.MatchString(u.GoUUID) {
 		return errors.New("uuid does not match regexp")
 	}
 
 	return nil
 }
+
+// IsNamed returns whether the UUID is a named-uuid (i.e: it refers to a row inserted
+// in the same transaction) rather than a real one
+func (u UUID) IsNamed() bool {
+	return u.validateUUID() != nil
+}
+
+// Validate returns an error if the UUID is neither a real UUID nor a valid
+// named-uuid, which must be an identifier (RFC7047 section 3.1)
+func (u UUID) Validate() error {
+	if !u.IsNamed() {
+		return nil
+	}
+	return validateNamedUUID(u.GoUUID)
+}
+
+func validateNamedUUID(name string) error {
+	if !validNamedUUID.MatchString(name) {
+		return fmt.Errorf("invalid named-uuid %q: must match %s", name, validNamedUUID)
+	}
+	return nil
+}